@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/jobs"
+)
+
+// Kinds of background job.
+const (
+	jobNotifyMentions = "notify_mentions"
+	jobBuildExport    = "build_export"
+)
+
+const (
+	succeededJobRetention = 7 * 24 * time.Hour
+	deadJobRetention      = 30 * 24 * time.Hour
+)
+
+// registerJobs tells runner how to run each kind of job, and schedules
+// the periodic ones.
+func (api *apiConfig) registerJobs(runner *jobs.Runner) {
+	runner.Handle(jobNotifyMentions, api.handleNotifyMentionsJob)
+	runner.Handle(jobBuildExport, api.handleBuildExportJob)
+
+	runner.Every("purge_deleted_users", time.Hour, api.purgeDeletedUsers)
+	runner.Every("purge_deleted_chirps", time.Hour, api.purgeDeletedChirps)
+	runner.Every("purge_chirp_events", time.Hour, api.purgeChirpEvents)
+	runner.Every("purge_expired_exports", time.Hour, api.purgeExpiredExports)
+	runner.Every("purge_read_notifications", time.Hour, api.purgeReadNotifications)
+	runner.Every("purge_webhook_deliveries", time.Hour, api.purgeWebhookDeliveries)
+	runner.Every("purge_orphaned_media", time.Hour, api.purgeOrphanedMedia)
+	runner.Every("purge_finished_jobs", time.Hour, api.purgeFinishedJobs)
+	runner.Every("deliver_webhooks", webhookPollInterval, api.deliverWebhooks)
+	runner.Every("publish_scheduled_chirps", envDuration("SCHEDULED_CHIRP_POLL_INTERVAL", defaultScheduledChirpPollInterval), api.publishScheduledChirps)
+}
+
+// envDuration reads a time.Duration from the environment, falling back to
+// def when the variable is unset or malformed.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxChirpLength = 140
+
+var (
+	errChirpTooLong  = errors.New("Chirp is too long")
+	errChirpNotFound = errors.New("chirp not found")
+	errNotChirpOwner = errors.New("not the chirp's author")
+)
+
+// cleanChirpBody enforces the rules every published chirp must meet. It
+// returns the body as it should be stored and whether the chirp should be
+// flagged for moderation.
+func (api *apiConfig) cleanChirpBody(body string) (string, bool, error) {
+	if len(body) > maxChirpLength {
+		return "", false, errChirpTooLong
+	}
+	return api.moderateText(body)
+}
+
+// createChirp creates a chirp together with its optional poll and media
+// attachments in one transaction, so a chirp never appears half-built.
+func (api *apiConfig) createChirp(ctx context.Context, params database.CreateChirpParams, poll *pollParameters, attachments []mediaParameters) (database.Chirp, error) {
+	var chirp database.Chirp
+	err := api.withTx(ctx, func(q *database.Queries) error {
+		var err error
+		chirp, err = q.CreateChirp(ctx, params)
+		if err != nil {
+			return err
+		}
+		if poll != nil {
+			err = createPoll(ctx, q, chirp.ID, *poll)
+			if err != nil {
+				return err
+			}
+		}
+		err = attachMedia(ctx, q, chirp, attachments)
+		if err != nil {
+			return err
+		}
+		return enqueueMentionNotifications(ctx, q, chirp)
+	})
+	return chirp, err
+}
+
+// deleteChirp deletes one of userID's chirps. Deleted chirps are kept as
+// tombstones so the author can undo the deletion; purgeDeletedChirps
+// removes them for good later. SoftDeleteChirp only matches the author's
+// own live chirps, so the chirp is only looked up afterwards, to tell a
+// chirp that isn't there from one that belongs to someone else.
+func (api *apiConfig) deleteChirp(ctx context.Context, userID, chirpID uuid.UUID) error {
+	n, err := api.db.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{
+		ID:     chirpID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err = api.db.GetChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errChirpNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return errNotChirpOwner
+}
+
+func chirpFromDB(chirp database.Chirp) Chirp {
+	return Chirp{
+		ID:             chirp.ID,
+		CreatedAt:      chirp.CreatedAt,
+		UpdatedAt:      chirp.UpdatedAt,
+		Body:           chirp.Body,
+		UserId:         chirp.UserID,
+		Pinned:         chirp.PinnedAt.Valid,
+		ContentWarning: chirp.ContentWarning,
+		Sensitive:      chirp.Sensitive,
+		Hidden:         chirp.HiddenAt.Valid,
+	}
+}
+
+// decorateChirps fills in the fields of chirps that aren't stored on the
+// chirp row itself: media, polls, whether the chirp starts collapsed, and
+// for signed-in viewers their own bookmarks and votes. Anonymous viewers
+// get the defaults.
+func (api *apiConfig) decorateChirps(ctx context.Context, viewerID uuid.UUID, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	err := api.loadChirpMedia(ctx, chirps)
+	if err != nil {
+		return err
+	}
+	err = api.attachPolls(ctx, viewerID, chirps)
+	if err != nil {
+		return err
+	}
+	// Anonymous viewers, and viewers who chose to hide sensitive chirps
+	// but still reached one, see it collapsed.
+	preference := sensitiveMediaCollapse
+	if viewerID != uuid.Nil {
+		viewer, err := api.db.GetUserByID(ctx, viewerID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			preference = viewer.SensitiveMedia
+		}
+	}
+	for i := range chirps {
+		chirps[i].Collapsed = chirps[i].ContentWarning != "" || (chirps[i].Sensitive && preference != sensitiveMediaShow)
+	}
+	if viewerID == uuid.Nil {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	bookmarked, err := api.db.GetBookmarkedChirpIDs(ctx, database.GetBookmarkedChirpIDsParams{
+		UserID:   viewerID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+	bookmarkedSet := make(map[uuid.UUID]bool, len(bookmarked))
+	for _, id := range bookmarked {
+		bookmarkedSet[id] = true
+	}
+	for i := range chirps {
+		chirps[i].BookmarkedByMe = bookmarkedSet[chirps[i].ID]
+	}
+	return nil
+}
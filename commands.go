@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Madlite/chirpy/internal/audit"
+	"github.com/Madlite/chirpy/internal/database"
+)
+
+const commandUsage = `usage: chirpy [command]
+
+With no command, chirpy serves the API.
+
+Commands:
+  audit verify    check the audit log's hash chain
+`
+
+// runCommand runs the command-line subcommand named by args and returns
+// the process exit code.
+func runCommand(ctx context.Context, db *sql.DB, args []string) int {
+	switch {
+	case len(args) == 2 && args[0] == "audit" && args[1] == "verify":
+		return runAuditVerify(ctx, db)
+	default:
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+}
+
+func runAuditVerify(ctx context.Context, db *sql.DB) int {
+	result, err := audit.Verify(ctx, database.New(db))
+	var chainErr *audit.ChainError
+	if errors.As(err, &chainErr) {
+		fmt.Printf("audit log is BROKEN after %d good events: %s\n", result.Events, chainErr)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error verifying audit log: %s\n", err)
+		return 1
+	}
+	fmt.Printf("audit log OK: %d events, head %s\n", result.Events, result.Head)
+	return 0
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// withTx runs fn inside a database transaction, committing if it returns
+// nil and rolling back otherwise.
+//
+// Writes that take more than one statement are kept out of the handlers,
+// in apiConfig methods next to the types they deal with (createChirp in
+// chirps.go, updateUser and startSession in users.go). That is the service
+// layer: handlers decode the request, call one of them and map its errors
+// to responses.
+func (api *apiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := api.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(api.db.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint
+// violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/auth"
+	"github.com/Madlite/chirpy/internal/database"
+)
+
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// handlerDeleteOwnUser schedules the caller's account for deletion. The
+// account is only removed once the grace period has passed; logging in
+// before then cancels the request.
+func (api *apiConfig) handlerDeleteOwnUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	passwordValid, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if err != nil || !passwordValid {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password")
+		return
+	}
+
+	err = api.db.RequestUserDeletion(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule account deletion")
+		return
+	}
+	err = api.db.RevokeUserRefreshTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke refresh tokens")
+		return
+	}
+
+	type response struct {
+		DeleteAfter time.Time `json:"delete_after"`
+	}
+	respondWithJSON(w, http.StatusAccepted, response{
+		DeleteAfter: time.Now().Add(api.deletionGrace),
+	})
+}
+
+// purgeDeletedUsers hard-deletes accounts whose grace period has expired.
+// Chirps and refresh tokens go with them through ON DELETE CASCADE. The
+// chirps are soft-deleted first, while their authors still exist, so the
+// stream's deletion events record who could see them. Export and media
+// rows are deleted first too, and the avatar keys collected, so their
+// files and blobs can be removed once the purge has committed.
+func (api *apiConfig) purgeDeletedUsers(ctx context.Context) {
+	cutoff := time.Now().Add(-api.deletionGrace)
+	var exportPaths, avatarKeys []sql.NullString
+	var media []database.DeleteMediaOfPurgedUsersRow
+	err := api.withTx(ctx, func(q *database.Queries) error {
+		err := q.SoftDeleteChirpsOfPurgedUsers(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		exportPaths, err = q.DeleteExportsOfPurgedUsers(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		media, err = q.DeleteMediaOfPurgedUsers(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		avatarKeys, err = q.PurgeDeletedUsers(ctx, cutoff)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error purging deleted users: %s", err)
+		return
+	}
+	removeExportArchives(exportPaths)
+	for _, m := range media {
+		api.deleteMediaBlobs(ctx, m.BlobKey, m.ThumbnailKey)
+	}
+	for _, key := range avatarKeys {
+		if !key.Valid {
+			continue
+		}
+		err = api.blobs.Delete(ctx, key.String)
+		if err != nil {
+			log.Printf("Error deleting avatar %s: %s", key.String, err)
+		}
+	}
+	if len(avatarKeys) > 0 {
+		log.Printf("Purged %d deleted users", len(avatarKeys))
+	}
+}
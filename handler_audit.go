@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/audit"
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip"`
+	Metadata   json.RawMessage `json:"metadata"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// recordAudit appends an event to the audit log, filling in the caller's
+// IP. Failing to record doesn't fail the request that caused the event.
+func (api *apiConfig) recordAudit(r *http.Request, e audit.Event) {
+	e.IP = clientIP(r)
+	_, err := audit.Record(r.Context(), api.dbConn, e)
+	if err != nil {
+		log.Printf("Error recording audit event %s: %s", e.Action, err)
+	}
+}
+
+// handlerGetAuditEvents lists audit events, newest first. Results can be
+// narrowed with ?actor_id=, ?action=, ?target_type=, ?target_id= and
+// ?since=, and paged with ?limit=, ?before= and ?before_id=.
+func (api *apiConfig) handlerGetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	limit, before, beforeID, err := parseSeqPagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	params := database.GetAuditEventsParams{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+		Before:     before,
+		BeforeID:   beforeID,
+		PageSize:   limit,
+	}
+	if v := query.Get("actor_id"); v != "" {
+		actorID, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid actor ID")
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+	if v := query.Get("since"); v != "" {
+		params.Since, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		params.Since = params.Since.UTC()
+	}
+	params.Before = params.Before.UTC()
+
+	events, err := api.db.GetAuditEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get audit events")
+		return
+	}
+	responseEvents := []AuditEvent{}
+	for _, event := range events {
+		responseEvents = append(responseEvents, auditEventFromDB(event))
+	}
+	respondWithJSON(w, http.StatusOK, responseEvents)
+}
+
+// clientIP returns the address the request came from. Forwarding headers
+// are ignored since any client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func auditEventFromDB(event database.AuditEvent) AuditEvent {
+	return AuditEvent{
+		ID:         event.ID,
+		CreatedAt:  event.CreatedAt,
+		ActorID:    nullUUIDPtr(event.ActorID),
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.Ip,
+		Metadata:   json.RawMessage(event.Metadata),
+		PrevHash:   event.PrevHash,
+		Hash:       event.Hash,
+	}
+}
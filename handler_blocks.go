@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// targetUser authenticates the caller and parses the {userID} path value,
+// refusing requests that target the caller themselves.
+func (api *apiConfig) targetUser(w http.ResponseWriter, r *http.Request) (userID, targetID uuid.UUID, ok bool) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, uuid.Nil, false
+	}
+	targetID, err = uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "Can't target yourself")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+// handlerBlockUser blocks the target user. Any follow between the two users,
+// in either direction, is removed in the same statement.
+func (api *apiConfig) handlerBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := api.targetUser(w, r)
+	if !ok {
+		return
+	}
+	_, err := api.db.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	err = api.db.CreateBlock(r.Context(), database.CreateBlockParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := api.targetUser(w, r)
+	if !ok {
+		return
+	}
+	err := api.db.DeleteBlock(r.Context(), database.DeleteBlockParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unblock user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerMuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := api.targetUser(w, r)
+	if !ok {
+		return
+	}
+	_, err := api.db.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	err = api.db.CreateMute(r.Context(), database.CreateMuteParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mute user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := api.targetUser(w, r)
+	if !ok {
+		return
+	}
+	err := api.db.DeleteMute(r.Context(), database.DeleteMuteParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unmute user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
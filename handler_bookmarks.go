@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxCollectionNameLength = 50
+
+type BookmarkCollection struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+type BookmarkedChirp struct {
+	Chirp
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+func (api *apiConfig) handlerCreateBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	name, ok := decodeCollectionName(w, r)
+	if !ok {
+		return
+	}
+	collection, err := api.db.CreateBookmarkCollection(r.Context(), database.CreateBookmarkCollectionParams{
+		UserID: userID,
+		Name:   name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "A collection with that name already exists")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create collection")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, collectionFromDB(collection))
+}
+
+func (api *apiConfig) handlerGetBookmarkCollections(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	collections, err := api.db.GetBookmarkCollections(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get collections")
+		return
+	}
+	responseCollections := []BookmarkCollection{}
+	for _, collection := range collections {
+		responseCollections = append(responseCollections, collectionFromDB(collection))
+	}
+	respondWithJSON(w, http.StatusOK, responseCollections)
+}
+
+func (api *apiConfig) handlerRenameBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	userID, collection, ok := api.collectionForRequest(w, r)
+	if !ok {
+		return
+	}
+	name, ok := decodeCollectionName(w, r)
+	if !ok {
+		return
+	}
+	renamed, err := api.db.RenameBookmarkCollection(r.Context(), database.RenameBookmarkCollectionParams{
+		ID:     collection.ID,
+		UserID: userID,
+		Name:   name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "A collection with that name already exists")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rename collection")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, collectionFromDB(renamed))
+}
+
+func (api *apiConfig) handlerDeleteBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	userID, collection, ok := api.collectionForRequest(w, r)
+	if !ok {
+		return
+	}
+	_, err := api.db.DeleteBookmarkCollection(r.Context(), database.DeleteBookmarkCollectionParams{
+		ID:     collection.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete collection")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerAddBookmark(w http.ResponseWriter, r *http.Request) {
+	userID, collection, ok := api.collectionForRequest(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		ChirpID uuid.UUID `json:"chirp_id"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	_, err = api.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       params.ChirpID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+	err = api.db.AddBookmark(r.Context(), database.AddBookmarkParams{
+		CollectionID: collection.ID,
+		ChirpID:      params.ChirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add bookmark")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	_, collection, ok := api.collectionForRequest(w, r)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+	_, err = api.db.RemoveBookmark(r.Context(), database.RemoveBookmarkParams{
+		CollectionID: collection.ID,
+		ChirpID:      chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove bookmark")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetBookmarkedChirps pages through a collection, most recently
+// bookmarked first. Chirps the caller can no longer see are skipped;
+// deleted chirps drop out of the collection on their own. The cursor is
+// the last chirp's bookmarked_at and ID.
+func (api *apiConfig) handlerGetBookmarkedChirps(w http.ResponseWriter, r *http.Request) {
+	userID, collection, ok := api.collectionForRequest(w, r)
+	if !ok {
+		return
+	}
+	limit, before, beforeID, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := api.db.GetBookmarkedChirps(r.Context(), database.GetBookmarkedChirpsParams{
+		CollectionID: collection.ID,
+		Before:       before,
+		BeforeID:     beforeID,
+		ViewerID:     userID,
+		PageSize:     limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get bookmarks")
+		return
+	}
+
+	chirps := make([]Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, chirpFromDB(row.Chirp))
+	}
+	err = api.decorateChirps(r.Context(), userID, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get bookmarks")
+		return
+	}
+	responseChirps := []BookmarkedChirp{}
+	for i, row := range rows {
+		responseChirps = append(responseChirps, BookmarkedChirp{
+			Chirp:        chirps[i],
+			BookmarkedAt: row.BookmarkedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responseChirps)
+}
+
+// collectionForRequest authenticates the caller and loads the
+// {collectionID} collection, which must belong to them.
+func (api *apiConfig) collectionForRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.BookmarkCollection, bool) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, database.BookmarkCollection{}, false
+	}
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid collection ID")
+		return uuid.Nil, database.BookmarkCollection{}, false
+	}
+	collection, err := api.db.GetBookmarkCollection(r.Context(), database.GetBookmarkCollectionParams{
+		ID:     collectionID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find collection")
+		return uuid.Nil, database.BookmarkCollection{}, false
+	}
+	return userID, collection, true
+}
+
+func decodeCollectionName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return "", false
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "Collection name can't be empty")
+		return "", false
+	}
+	if utf8.RuneCountInString(name) > maxCollectionNameLength {
+		respondWithError(w, http.StatusBadRequest, "Collection name is too long")
+		return "", false
+	}
+	return name, true
+}
+
+func collectionFromDB(collection database.BookmarkCollection) BookmarkCollection {
+	return BookmarkCollection{
+		ID:        collection.ID,
+		CreatedAt: collection.CreatedAt,
+		UpdatedAt: collection.UpdatedAt,
+		Name:      collection.Name,
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultChirpUndoWindow    = 10 * time.Minute
+	defaultTombstoneRetention = 7 * 24 * time.Hour
+)
+
+// ChirpTombstone stands in for a deleted chirp until it is purged.
+// UndoUntil is only shown to the author, and only while they can still
+// restore the chirp.
+type ChirpTombstone struct {
+	ID        uuid.UUID  `json:"id"`
+	Deleted   bool       `json:"deleted"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	UndoUntil *time.Time `json:"undo_until,omitempty"`
+}
+
+// respondWithChirpTombstone answers a request for a chirp that isn't
+// visible: 410 Gone with a tombstone if it was deleted and the viewer could
+// otherwise see it, 404 otherwise.
+func (api *apiConfig) respondWithChirpTombstone(w http.ResponseWriter, r *http.Request, chirpID, viewerID uuid.UUID) {
+	chirp, err := api.db.GetChirpTombstone(r.Context(), database.GetChirpTombstoneParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp")
+		return
+	}
+	tombstone := ChirpTombstone{
+		ID:        chirp.ID,
+		Deleted:   true,
+		DeletedAt: chirp.DeletedAt.Time,
+	}
+	undoUntil := chirp.DeletedAt.Time.Add(api.chirpUndo)
+	if chirp.UserID == viewerID && time.Now().Before(undoUntil) {
+		tombstone.UndoUntil = &undoUntil
+	}
+	respondWithJSON(w, http.StatusGone, tombstone)
+}
+
+// handlerRestoreChirp undoes the deletion of one of the caller's chirps,
+// as long as the undo window hasn't passed.
+func (api *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+	if !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	chirp, err := api.db.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:           chirpID,
+		UserID:       userID,
+		DeletedAfter: time.Now().Add(-api.chirpUndo),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find a recently deleted chirp")
+		return
+	}
+
+	responseChirps := []Chirp{chirpFromDB(chirp)}
+	err = api.decorateChirps(r.Context(), userID, responseChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, responseChirps[0])
+}
+
+// purgeDeletedChirps hard-deletes tombstones once they have been kept for
+// the retention period, which is never shorter than the undo window.
+// Attached media is left to purgeOrphanedMedia.
+func (api *apiConfig) purgeDeletedChirps(ctx context.Context) {
+	retention := max(api.tombstoneTTL, api.chirpUndo)
+	n, err := api.db.PurgeDeletedChirps(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Error purging deleted chirps: %s", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Purged %d deleted chirps", n)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxConversationParticipants = 10
+	maxMessageLength            = 2000
+)
+
+type Conversation struct {
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Participants []Participant `json:"participants"`
+	UnreadCount  int64         `json:"unread_count"`
+}
+
+type Participant struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	SenderID       uuid.UUID   `json:"sender_id"`
+	Body           string      `json:"body"`
+	ReadBy         []uuid.UUID `json:"read_by"`
+}
+
+// handlerCreateConversation starts a conversation with one or more users.
+// Starting a one-to-one conversation that already exists returns the
+// existing one instead of creating a duplicate.
+func (api *apiConfig) handlerCreateConversation(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	if !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	type parameters struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+		Body           string      `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if utf8.RuneCountInString(params.Body) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "Message is too long")
+		return
+	}
+
+	seen := map[uuid.UUID]bool{userID: true}
+	var others []uuid.UUID
+	for _, id := range params.ParticipantIDs {
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		respondWithError(w, http.StatusBadRequest, "A conversation needs at least one other participant")
+		return
+	}
+	if len(others)+1 > maxConversationParticipants {
+		respondWithError(w, http.StatusBadRequest, "Too many participants")
+		return
+	}
+	ok, err := api.canMessageAll(r.Context(), userID, others)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check privacy settings")
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusForbidden, "Can't message one or more of these users")
+		return
+	}
+
+	var conversation database.Conversation
+	status := http.StatusCreated
+	if len(others) == 1 {
+		conversation, err = api.db.FindDirectConversation(r.Context(), database.FindDirectConversationParams{
+			UserA: userID,
+			UserB: others[0],
+		})
+		if err == nil {
+			status = http.StatusOK
+		}
+	}
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		if status == http.StatusCreated {
+			conversation, err = q.CreateConversation(r.Context())
+			if err != nil {
+				return err
+			}
+			for _, id := range append([]uuid.UUID{userID}, others...) {
+				err = q.AddConversationParticipant(r.Context(), database.AddConversationParticipantParams{
+					ConversationID: conversation.ID,
+					UserID:         id,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if params.Body == "" {
+			return nil
+		}
+		_, err = createMessage(r.Context(), q, conversation.ID, userID, params.Body)
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation")
+		return
+	}
+
+	responseConversations, err := api.conversationsWithParticipants(r.Context(), []database.GetConversationsForUserRow{{
+		ID:        conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+	}})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get participants")
+		return
+	}
+	respondWithJSON(w, status, responseConversations[0])
+}
+
+// handlerGetConversations lists the caller's conversations, most recently
+// active first, with the number of unread messages in each.
+func (api *apiConfig) handlerGetConversations(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	limit, before, beforeID, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	conversations, err := api.db.GetConversationsForUser(r.Context(), database.GetConversationsForUserParams{
+		UserID:   userID,
+		Before:   before,
+		BeforeID: beforeID,
+		PageSize: limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get conversations")
+		return
+	}
+	responseConversations, err := api.conversationsWithParticipants(r.Context(), conversations)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get participants")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, responseConversations)
+}
+
+// handlerGetMessages pages backwards through a conversation, newest first.
+func (api *apiConfig) handlerGetMessages(w http.ResponseWriter, r *http.Request) {
+	_, conversation, ok := api.conversationForRequest(w, r)
+	if !ok {
+		return
+	}
+	limit, before, beforeID, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	messages, err := api.db.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversation.ID,
+		Before:         before,
+		BeforeID:       beforeID,
+		PageSize:       limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get messages")
+		return
+	}
+	participants, err := api.db.GetConversationParticipants(r.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get participants")
+		return
+	}
+
+	responseMessages := []Message{}
+	for _, message := range messages {
+		responseMessages = append(responseMessages, messageFromDB(message, participants))
+	}
+	respondWithJSON(w, http.StatusOK, responseMessages)
+}
+
+func (api *apiConfig) handlerSendMessage(w http.ResponseWriter, r *http.Request) {
+	userID, conversation, ok := api.conversationForRequest(w, r)
+	if !ok || !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if params.Body == "" {
+		respondWithError(w, http.StatusBadRequest, "Message can't be empty")
+		return
+	}
+	if utf8.RuneCountInString(params.Body) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "Message is too long")
+		return
+	}
+
+	participants, err := api.db.GetConversationParticipants(r.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get participants")
+		return
+	}
+	var others []uuid.UUID
+	for _, p := range participants {
+		if p.UserID != userID {
+			others = append(others, p.UserID)
+		}
+	}
+	ok, err = api.canMessageAll(r.Context(), userID, others)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check privacy settings")
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusForbidden, "Can't message one or more participants")
+		return
+	}
+
+	var message database.Message
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		message, err = createMessage(r.Context(), q, conversation.ID, userID, params.Body)
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, messageFromDB(message, nil))
+}
+
+// handlerMarkConversationRead records that the caller has read everything
+// in the conversation up to now. Other participants see this through
+// last_read_at and the read_by list on each message.
+func (api *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	userID, conversation, ok := api.conversationForRequest(w, r)
+	if !ok {
+		return
+	}
+	err := api.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation read")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// conversationForRequest authenticates the caller and loads the
+// {conversationID} conversation, which the caller must take part in.
+func (api *apiConfig) conversationForRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Conversation, bool) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, database.Conversation{}, false
+	}
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return uuid.Nil, database.Conversation{}, false
+	}
+	conversation, err := api.db.GetConversation(r.Context(), database.GetConversationParams{
+		ID:     conversationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find conversation")
+		return uuid.Nil, database.Conversation{}, false
+	}
+	return userID, conversation, true
+}
+
+// canMessageAll reports whether senderID may message every recipient,
+// taking blocks and protected accounts into account.
+func (api *apiConfig) canMessageAll(ctx context.Context, senderID uuid.UUID, recipients []uuid.UUID) (bool, error) {
+	for _, recipientID := range recipients {
+		ok, err := api.db.CanMessageUser(ctx, database.CanMessageUserParams{
+			RecipientID: recipientID,
+			SenderID:    senderID,
+		})
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (api *apiConfig) conversationsWithParticipants(ctx context.Context, conversations []database.GetConversationsForUserRow) ([]Conversation, error) {
+	ids := make([]uuid.UUID, 0, len(conversations))
+	for _, c := range conversations {
+		ids = append(ids, c.ID)
+	}
+	participants, err := api.db.GetConversationParticipants(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byConversation := map[uuid.UUID][]Participant{}
+	for _, p := range participants {
+		byConversation[p.ConversationID] = append(byConversation[p.ConversationID], Participant{
+			UserID:     p.UserID,
+			LastReadAt: nullTimePtr(p.LastReadAt),
+		})
+	}
+
+	responseConversations := []Conversation{}
+	for _, c := range conversations {
+		responseConversations = append(responseConversations, Conversation{
+			ID:           c.ID,
+			CreatedAt:    c.CreatedAt,
+			UpdatedAt:    c.UpdatedAt,
+			Participants: byConversation[c.ID],
+			UnreadCount:  c.UnreadCount,
+		})
+	}
+	return responseConversations, nil
+}
+
+// createMessage stores a message, bumps the conversation to the top of
+// everyone's list and marks it read for the sender.
+func createMessage(ctx context.Context, q *database.Queries, conversationID, senderID uuid.UUID, body string) (database.Message, error) {
+	message, err := q.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	err = q.TouchConversation(ctx, conversationID)
+	if err != nil {
+		return database.Message{}, err
+	}
+	err = q.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         senderID,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	return message, nil
+}
+
+// messageFromDB converts a message, listing as readers the participants
+// other than the sender who have read up to or past it.
+func messageFromDB(message database.Message, participants []database.ConversationParticipant) Message {
+	readBy := []uuid.UUID{}
+	for _, p := range participants {
+		if p.UserID == message.SenderID || !p.LastReadAt.Valid {
+			continue
+		}
+		if !p.LastReadAt.Time.Before(message.CreatedAt) {
+			readBy = append(readBy, p.UserID)
+		}
+	}
+	return Message{
+		ID:             message.ID,
+		CreatedAt:      message.CreatedAt,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+		ReadBy:         readBy,
+	}
+}
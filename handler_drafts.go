@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Drafts may run past the chirp length limit while they're being worked
+// on; the limit is only enforced when a draft is published.
+const maxDraftLength = 10000
+
+type Draft struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+}
+
+func (api *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	body, ok := decodeDraftBody(w, r)
+	if !ok {
+		return
+	}
+	draft, err := api.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID: userID,
+		Body:   body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create draft")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, draftFromDB(draft))
+}
+
+func (api *apiConfig) handlerGetDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	drafts, err := api.db.GetDrafts(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get drafts")
+		return
+	}
+	responseDrafts := []Draft{}
+	for _, draft := range drafts {
+		responseDrafts = append(responseDrafts, draftFromDB(draft))
+	}
+	respondWithJSON(w, http.StatusOK, responseDrafts)
+}
+
+func (api *apiConfig) handlerGetDraft(w http.ResponseWriter, r *http.Request) {
+	_, draft, ok := api.draftForRequest(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, draftFromDB(draft))
+}
+
+func (api *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	userID, draft, ok := api.draftForRequest(w, r)
+	if !ok {
+		return
+	}
+	body, ok := decodeDraftBody(w, r)
+	if !ok {
+		return
+	}
+	updated, err := api.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:     draft.ID,
+		UserID: userID,
+		Body:   body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update draft")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, draftFromDB(updated))
+}
+
+func (api *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	userID, draft, ok := api.draftForRequest(w, r)
+	if !ok {
+		return
+	}
+	_, err := api.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draft.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete draft")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerPublishDraft turns a draft into a chirp. The draft goes through
+// the same checks as handlerCreateChirp, and is deleted in the same
+// statement that creates the chirp so it can't be published twice.
+func (api *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	userID, draft, ok := api.draftForRequest(w, r)
+	if !ok || !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	body, flagged, err := api.cleanChirpBody(draft.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var chirp database.Chirp
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		chirp, err = q.PublishDraft(r.Context(), database.PublishDraftParams{
+			ID:        draft.ID,
+			UserID:    userID,
+			UpdatedAt: draft.UpdatedAt,
+			Body:      body,
+			Flagged:   flagged,
+		})
+		if err != nil {
+			return err
+		}
+		return enqueueMentionNotifications(r.Context(), q, chirp)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusConflict, "Draft was changed or deleted while publishing")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish draft")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, chirpFromDB(chirp))
+}
+
+// draftForRequest authenticates the caller and loads the {draftID} draft,
+// which must belong to them.
+func (api *apiConfig) draftForRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Draft, bool) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, database.Draft{}, false
+	}
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID")
+		return uuid.Nil, database.Draft{}, false
+	}
+	draft, err := api.db.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find draft")
+		return uuid.Nil, database.Draft{}, false
+	}
+	return userID, draft, true
+}
+
+func decodeDraftBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return "", false
+	}
+	if utf8.RuneCountInString(params.Body) > maxDraftLength {
+		respondWithError(w, http.StatusBadRequest, "Draft is too long")
+		return "", false
+	}
+	return params.Body, true
+}
+
+func draftFromDB(draft database.Draft) Draft {
+	return Draft{
+		ID:        draft.ID,
+		CreatedAt: draft.CreatedAt,
+		UpdatedAt: draft.UpdatedAt,
+		Body:      draft.Body,
+	}
+}
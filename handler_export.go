@@ -0,0 +1,410 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/Madlite/chirpy/internal/jobs"
+	"github.com/google/uuid"
+)
+
+const (
+	exportChirpPageSize = 500
+	exportRetention     = 7 * 24 * time.Hour
+	exportMaxAttempts   = 3
+)
+
+type Export struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func (api *apiConfig) handlerCreateExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	var export database.Export
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		export, err = q.CreateExport(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		_, err = jobs.Enqueue(r.Context(), q, jobs.Job{
+			Kind:        jobBuildExport,
+			Payload:     exportJob{ExportID: export.ID, UserID: userID},
+			UniqueKey:   jobBuildExport + ":" + export.ID.String(),
+			MaxAttempts: exportMaxAttempts,
+		})
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create export")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, exportFromDB(export))
+}
+
+// handlerGetExport reports the status of an export. Once the archive is
+// ready it can be downloaded by passing ?download=true.
+func (api *apiConfig) handlerGetExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID")
+		return
+	}
+	export, err := api.db.GetExport(r.Context(), database.GetExportParams{
+		ID:     exportID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find export")
+		return
+	}
+
+	if r.URL.Query().Get("download") != "true" {
+		respondWithJSON(w, http.StatusOK, exportFromDB(export))
+		return
+	}
+	if export.Status != "ready" || !export.FilePath.Valid {
+		respondWithError(w, http.StatusConflict, "Export is not ready")
+		return
+	}
+	f, err := os.Open(export.FilePath.String)
+	if err != nil {
+		respondWithError(w, http.StatusGone, "Export archive is no longer available")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.ID))
+	http.ServeContent(w, r, "", export.CompletedAt.Time, f)
+}
+
+type exportJob struct {
+	ExportID uuid.UUID `json:"export_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+// handleBuildExportJob builds the archive for a pending export. Writing
+// the archive is retried like any job; the export is only marked failed
+// once its last attempt fails.
+func (api *apiConfig) handleBuildExportJob(ctx context.Context, payload json.RawMessage) error {
+	var job exportJob
+	err := json.Unmarshal(payload, &job)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	export, err := api.db.GetExport(ctx, database.GetExportParams{
+		ID:     job.ExportID,
+		UserID: job.UserID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// The user was deleted, and their exports with them.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if export.Status != "pending" {
+		return nil
+	}
+
+	path, err := api.writeExportArchive(ctx, export)
+	if err != nil {
+		log.Printf("Error building export %s: %s", export.ID, err)
+		if jobs.Attempt(ctx) < exportMaxAttempts {
+			return err
+		}
+		return api.db.MarkExportFailed(ctx, database.MarkExportFailedParams{
+			ID:    export.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		})
+	}
+	n, err := api.db.MarkExportReady(ctx, database.MarkExportReadyParams{
+		ID:       export.ID,
+		FilePath: sql.NullString{String: path, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// The export was purged, with its user, while the archive was
+		// being written. Nothing refers to the file any more.
+		removeExportArchives([]sql.NullString{{String: path, Valid: true}})
+	}
+	return nil
+}
+
+// writeExportArchive streams the user's data into a zip file on disk and
+// returns its path. Chirps are read in pages so memory use stays flat no
+// matter how long the user's history is.
+func (api *apiConfig) writeExportArchive(ctx context.Context, export database.Export) (string, error) {
+	err := os.MkdirAll(api.exportDir, 0o700)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(api.exportDir, export.ID.String()+".zip")
+	tmp, err := os.CreateTemp(api.exportDir, export.ID.String()+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	user, err := api.db.GetUserByID(ctx, export.UserID)
+	if err != nil {
+		return "", err
+	}
+	type profile struct {
+		ID                  uuid.UUID  `json:"id"`
+		CreatedAt           time.Time  `json:"created_at"`
+		UpdatedAt           time.Time  `json:"updated_at"`
+		Email               string     `json:"email"`
+		DisplayName         string     `json:"display_name"`
+		Bio                 string     `json:"bio"`
+		Location            string     `json:"location"`
+		Website             string     `json:"website"`
+		IsChirpyRed         bool       `json:"is_chirpy_red"`
+		DeletionRequestedAt *time.Time `json:"deletion_requested_at"`
+	}
+	err = writeZipJSON(zw, "profile.json", profile{
+		ID:                  user.ID,
+		CreatedAt:           user.CreatedAt,
+		UpdatedAt:           user.UpdatedAt,
+		Email:               user.Email,
+		DisplayName:         user.DisplayName,
+		Bio:                 user.Bio,
+		Location:            user.Location,
+		Website:             user.Website,
+		IsChirpyRed:         user.IsChirpyRed,
+		DeletionRequestedAt: nullTimePtr(user.DeletionRequestedAt),
+	})
+	if err != nil {
+		return "", err
+	}
+	err = api.writeExportChirpsJSON(ctx, zw, user.ID)
+	if err != nil {
+		return "", err
+	}
+	err = api.writeExportChirpsCSV(ctx, zw, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	tokens, err := api.db.GetUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	type session struct {
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+	sessions := []session{}
+	for _, token := range tokens {
+		sessions = append(sessions, session{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+			RevokedAt: nullTimePtr(token.RevokedAt),
+		})
+	}
+	err = writeZipJSON(zw, "sessions.json", sessions)
+	if err != nil {
+		return "", err
+	}
+
+	events, err := api.db.GetUserSubscriptionEvents(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	type subscriptionEvent struct {
+		Event     string    `json:"event"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	history := []subscriptionEvent{}
+	for _, event := range events {
+		history = append(history, subscriptionEvent{
+			Event:     event.Event,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+	err = writeZipJSON(zw, "subscriptions.json", history)
+	if err != nil {
+		return "", err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return "", err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// forEachExportChirp walks all of a user's chirps in creation order, one
+// page at a time.
+func (api *apiConfig) forEachExportChirp(ctx context.Context, userID uuid.UUID, fn func(database.Chirp) error) error {
+	params := database.GetChirpsAuthorPageParams{
+		UserID:   userID,
+		PageSize: exportChirpPageSize,
+	}
+	for {
+		chirps, err := api.db.GetChirpsAuthorPage(ctx, params)
+		if err != nil {
+			return err
+		}
+		for _, chirp := range chirps {
+			err = fn(chirp)
+			if err != nil {
+				return err
+			}
+		}
+		if len(chirps) < exportChirpPageSize {
+			return nil
+		}
+		last := chirps[len(chirps)-1]
+		params.AfterCreatedAt = last.CreatedAt
+		params.AfterID = last.ID
+	}
+}
+
+func (api *apiConfig) writeExportChirpsJSON(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	f, err := zw.Create("chirps.json")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, "[")
+	if err != nil {
+		return err
+	}
+	first := true
+	err = api.forEachExportChirp(ctx, userID, func(chirp database.Chirp) error {
+		data, err := json.Marshal(chirpFromDB(chirp))
+		if err != nil {
+			return err
+		}
+		if !first {
+			_, err = io.WriteString(f, ",")
+			if err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = f.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, "]\n")
+	return err
+}
+
+func (api *apiConfig) writeExportChirpsCSV(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	f, err := zw.Create("chirps.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	err = cw.Write([]string{"id", "created_at", "updated_at", "body"})
+	if err != nil {
+		return err
+	}
+	err = api.forEachExportChirp(ctx, userID, func(chirp database.Chirp) error {
+		return cw.Write([]string{
+			chirp.ID.String(),
+			chirp.CreatedAt.Format(time.RFC3339),
+			chirp.UpdatedAt.Format(time.RFC3339),
+			chirp.Body,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// purgeExpiredExports removes exports, and their archives, once they are
+// older than the retention period.
+func (api *apiConfig) purgeExpiredExports(ctx context.Context) {
+	paths, err := api.db.DeleteExpiredExports(ctx, time.Now().Add(-exportRetention))
+	if err != nil {
+		log.Printf("Error purging expired exports: %s", err)
+		return
+	}
+	removeExportArchives(paths)
+}
+
+// removeExportArchives deletes the archives of exports whose rows are gone.
+func removeExportArchives(paths []sql.NullString) {
+	for _, path := range paths {
+		if !path.Valid {
+			continue
+		}
+		err := os.Remove(path.String)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing export archive %s: %s", path.String, err)
+		}
+	}
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func exportFromDB(export database.Export) Export {
+	return Export{
+		ID:          export.ID,
+		CreatedAt:   export.CreatedAt,
+		Status:      export.Status,
+		Error:       export.Error.String,
+		CompletedAt: nullTimePtr(export.CompletedAt),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
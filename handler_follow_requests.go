@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type FollowRequest struct {
+	FollowerID  uuid.UUID `json:"follower_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+}
+
+// handlerUpdatePrivacy toggles whether the caller's account is protected.
+// Turning protection off approves every pending follow request.
+func (api *apiConfig) handlerUpdatePrivacy(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	type parameters struct {
+		IsProtected bool `json:"is_protected"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.UpdateUserProtected(r.Context(), database.UpdateUserProtectedParams{
+			ID:          userID,
+			IsProtected: params.IsProtected,
+		})
+		if err != nil || params.IsProtected {
+			return err
+		}
+		followerIDs, err := q.AcceptAllFollowRequests(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		for _, followerID := range followerIDs {
+			err = notifyFollowAccepted(r.Context(), q, userID, followerID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update privacy settings")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, params)
+}
+
+func (api *apiConfig) handlerGetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	requests, err := api.db.GetFollowRequests(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get follow requests")
+		return
+	}
+	responseRequests := []FollowRequest{}
+	for _, request := range requests {
+		fr := FollowRequest{
+			FollowerID:  request.FollowerID,
+			CreatedAt:   request.CreatedAt,
+			DisplayName: request.DisplayName,
+		}
+		if request.AvatarKey.Valid {
+			fr.AvatarURL = fmt.Sprintf("/api/users/%s/avatar", request.FollowerID)
+		}
+		responseRequests = append(responseRequests, fr)
+	}
+	respondWithJSON(w, http.StatusOK, responseRequests)
+}
+
+func (api *apiConfig) handlerApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	userID, followerID, ok := api.targetUser(w, r)
+	if !ok {
+		return
+	}
+	var n int64
+	err := api.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		n, err = q.ApproveFollowRequest(r.Context(), database.ApproveFollowRequestParams{
+			FollowerID: followerID,
+			FolloweeID: userID,
+		})
+		if err != nil || n == 0 {
+			return err
+		}
+		return notifyFollowAccepted(r.Context(), q, userID, followerID)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve follow request")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "No pending follow request from this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerRejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	userID, followerID, ok := api.targetUser(w, r)
+	if !ok {
+		return
+	}
+	n, err := api.db.RejectFollowRequest(r.Context(), database.RejectFollowRequestParams{
+		FollowerID: followerID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reject follow request")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "No pending follow request from this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyFollowAccepted tells followerID that userID approved their follow
+// request. userID was told about the request when it was made, so they
+// aren't notified again. q should be the transaction that approved it.
+func notifyFollowAccepted(ctx context.Context, q *database.Queries, userID, followerID uuid.UUID) error {
+	return createNotification(ctx, q, notification{
+		UserID:  followerID,
+		Kind:    notificationFollowAccepted,
+		ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+}
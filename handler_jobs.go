@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/audit"
+	"github.com/Madlite/chirpy/internal/database"
+)
+
+type JobSummary struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Kind        string     `json:"kind"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"`
+	Attempts    int32      `json:"attempts"`
+	MaxAttempts int32      `json:"max_attempts"`
+	RunAt       time.Time  `json:"run_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// handlerGetJobs lists background jobs, newest first. ?status=dead shows
+// the ones that gave up; pages are requested with ?limit=, ?before= and
+// ?before_id=.
+func (api *apiConfig) handlerGetJobs(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	limit, before, beforeID, err := parseSeqPagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.GetJobsParams{
+		Before:   before.UTC(),
+		BeforeID: beforeID,
+		PageSize: limit,
+	}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case "queued", "running", "succeeded", "dead":
+		params.Status.String, params.Status.Valid = status, true
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be queued, running, succeeded or dead")
+		return
+	}
+
+	jobs, err := api.db.GetJobs(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get jobs")
+		return
+	}
+	responseJobs := []JobSummary{}
+	for _, job := range jobs {
+		responseJobs = append(responseJobs, JobSummary{
+			ID:          job.ID,
+			CreatedAt:   job.CreatedAt,
+			Kind:        job.Kind,
+			Payload:     job.Payload,
+			Status:      job.Status,
+			Attempts:    job.Attempts,
+			MaxAttempts: job.MaxAttempts,
+			RunAt:       job.RunAt,
+			LastError:   job.LastError,
+			FinishedAt:  nullTimePtr(job.FinishedAt),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responseJobs)
+}
+
+// handlerRetryJob queues a dead job to run again, with its attempts reset.
+func (api *apiConfig) handlerRetryJob(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	jobID, err := strconv.ParseInt(r.PathValue("jobID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+	n, err := api.db.RequeueDeadJob(r.Context(), jobID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retry job")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find a dead job with that ID")
+		return
+	}
+	api.recordAudit(r, audit.Event{
+		Action:     audit.ActionJobRetried,
+		TargetType: "job",
+		TargetID:   strconv.FormatInt(jobID, 10),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeFinishedJobs removes old finished jobs. Dead ones are kept longer,
+// so there's time to look into why they failed.
+func (api *apiConfig) purgeFinishedJobs(ctx context.Context) {
+	now := time.Now().UTC()
+	_, err := api.db.PurgeFinishedJobs(ctx, database.PurgeFinishedJobsParams{
+		SucceededBefore: now.Add(-succeededJobRetention),
+		DeadBefore:      now.Add(-deadJobRetention),
+	})
+	if err != nil {
+		log.Printf("Error purging finished jobs: %s", err)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/blob"
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/Madlite/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+const (
+	maxMediaSize       = 5 << 20
+	maxMediaDimension  = 4096
+	maxMediaFrames     = 500
+	maxMediaPixels     = 64 << 20
+	mediaThumbnailSize = 400
+	maxChirpMedia      = 4
+	maxAltTextLength   = 1000
+	orphanedMediaGrace = 24 * time.Hour
+)
+
+var errMediaUnavailable = errors.New("Media not found or already attached to a chirp")
+
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	AltText      string    `json:"alt_text"`
+}
+
+// mediaParameters references an uploaded image in the payload accepted by
+// handlerCreateChirp.
+type mediaParameters struct {
+	ID      uuid.UUID `json:"id"`
+	AltText string    `json:"alt_text"`
+}
+
+func validateMediaParameters(attachments []mediaParameters) error {
+	if len(attachments) > maxChirpMedia {
+		return fmt.Errorf("A chirp can have at most %d media attachments", maxChirpMedia)
+	}
+	seen := make(map[uuid.UUID]bool, len(attachments))
+	for _, attachment := range attachments {
+		if seen[attachment.ID] {
+			return errors.New("Media can only be attached once")
+		}
+		seen[attachment.ID] = true
+		if utf8.RuneCountInString(attachment.AltText) > maxAltTextLength {
+			return errors.New("Alt text is too long")
+		}
+	}
+	return nil
+}
+
+// handlerUploadMedia stores the raw request body as an image that can
+// later be attached to a chirp. Metadata is stripped and a thumbnail is
+// generated before anything is written to the blob store.
+func (api *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	if !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Media must be a PNG, JPEG, GIF or WebP image")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMediaSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Media is too large")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Couldn't read media")
+		return
+	}
+	if http.DetectContentType(data) != contentType {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Media content doesn't match its Content-Type")
+		return
+	}
+	img, err := media.Process(contentType, data, media.Limits{
+		MaxWidth:      maxMediaDimension,
+		MaxHeight:     maxMediaDimension,
+		MaxFrames:     maxMediaFrames,
+		MaxPixels:     maxMediaPixels,
+		ThumbnailSize: mediaThumbnailSize,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Images can be at most %dx%d pixels", maxMediaDimension, maxMediaDimension))
+		case errors.Is(err, media.ErrTooLong):
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Animations can be at most %d frames and %d pixels across all frames", maxMediaFrames, maxMediaPixels))
+		case errors.Is(err, media.ErrInvalidImage):
+			respondWithError(w, http.StatusBadRequest, "Couldn't read image")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't process image")
+		}
+		return
+	}
+
+	mediaID := uuid.New()
+	key := fmt.Sprintf("media/%s%s", mediaID, ext)
+	err = api.blobs.Put(r.Context(), key, bytes.NewReader(img.Data))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store media")
+		return
+	}
+	var thumbnailKey sql.NullString
+	if img.Thumbnail != nil {
+		thumbnailKey.String = fmt.Sprintf("media/%s-thumb%s", mediaID, imageExtensions[img.ThumbnailContentType])
+		thumbnailKey.Valid = true
+		err = api.blobs.Put(r.Context(), thumbnailKey.String, bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			api.blobs.Delete(r.Context(), key)
+			respondWithError(w, http.StatusInternalServerError, "Couldn't store media")
+			return
+		}
+	}
+
+	dbMedia, err := api.db.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:           mediaID,
+		UserID:       userID,
+		ContentType:  img.ContentType,
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		SizeBytes:    int32(len(img.Data)),
+		BlobKey:      key,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		api.deleteMediaBlobs(r.Context(), key, thumbnailKey)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create media")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, mediaFromDB(dbMedia))
+}
+
+func (api *apiConfig) handlerGetMedia(w http.ResponseWriter, r *http.Request) {
+	api.serveMedia(w, r, false)
+}
+
+func (api *apiConfig) handlerGetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	api.serveMedia(w, r, true)
+}
+
+// serveMedia streams an image, or its thumbnail, to anyone who can see the
+// chirp it's attached to. Unattached uploads are only visible to their
+// owner. Images without a thumbnail fall back to the full image.
+func (api *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+	dbMedia, err := api.db.GetMedia(r.Context(), mediaID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media")
+		return
+	}
+	viewerID := api.viewerID(r)
+	if dbMedia.ChirpID.Valid {
+		_, err = api.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+			ID:       dbMedia.ChirpID.UUID,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find media")
+			return
+		}
+	} else if dbMedia.UserID != viewerID {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media")
+		return
+	}
+
+	key, contentType := dbMedia.BlobKey, dbMedia.ContentType
+	if thumbnail && dbMedia.ThumbnailKey.Valid {
+		key = dbMedia.ThumbnailKey.String
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	rc, err := api.blobs.Open(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find media")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't open media")
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, rc)
+}
+
+// attachMedia links uploads to a chirp that is being created with q. Each
+// upload must belong to the chirp's author and not be attached already.
+func attachMedia(ctx context.Context, q *database.Queries, chirp database.Chirp, attachments []mediaParameters) error {
+	for i, attachment := range attachments {
+		n, err := q.AttachMedia(ctx, database.AttachMediaParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: sql.NullInt32{Int32: int32(i), Valid: true},
+			AltText:  attachment.AltText,
+			ID:       attachment.ID,
+			UserID:   chirp.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errMediaUnavailable
+		}
+	}
+	return nil
+}
+
+// loadChirpMedia fills in the attachments of chirps in a single query.
+func (api *apiConfig) loadChirpMedia(ctx context.Context, chirps []Chirp) error {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	rows, err := api.db.GetMediaForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byChirp := make(map[uuid.UUID][]Media, len(chirps))
+	for _, row := range rows {
+		byChirp[row.ChirpID.UUID] = append(byChirp[row.ChirpID.UUID], mediaFromDB(row))
+	}
+	for i := range chirps {
+		chirps[i].Media = byChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+// purgeOrphanedMedia removes uploads that were never attached to a chirp,
+// or whose chirp has since been deleted, once the grace period is over.
+func (api *apiConfig) purgeOrphanedMedia(ctx context.Context) {
+	rows, err := api.db.DeleteOrphanedMedia(ctx, time.Now().Add(-orphanedMediaGrace))
+	if err != nil {
+		log.Printf("Error purging orphaned media: %s", err)
+		return
+	}
+	for _, row := range rows {
+		api.deleteMediaBlobs(ctx, row.BlobKey, row.ThumbnailKey)
+	}
+}
+
+func (api *apiConfig) deleteMediaBlobs(ctx context.Context, key string, thumbnailKey sql.NullString) {
+	keys := []string{key}
+	if thumbnailKey.Valid {
+		keys = append(keys, thumbnailKey.String)
+	}
+	for _, k := range keys {
+		err := api.blobs.Delete(ctx, k)
+		if err != nil {
+			log.Printf("Error deleting media blob %s: %s", k, err)
+		}
+	}
+}
+
+func mediaFromDB(m database.Medium) Media {
+	return Media{
+		ID:           m.ID,
+		ContentType:  m.ContentType,
+		Width:        m.Width,
+		Height:       m.Height,
+		URL:          fmt.Sprintf("/api/media/%s", m.ID),
+		ThumbnailURL: fmt.Sprintf("/api/media/%s/thumbnail", m.ID),
+		AltText:      m.AltText,
+	}
+}
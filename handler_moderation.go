@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/audit"
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	moderationQueueReportLimit  = 1000
+	moderationQueueFlaggedLimit = 100
+)
+
+// Moderation actions.
+const (
+	actionDismiss     = "dismiss"
+	actionHideChirp   = "hide_chirp"
+	actionRemoveChirp = "remove_chirp"
+	actionSuspendUser = "suspend_user"
+
+	actionUnsuspendUser = "unsuspend_user"
+	actionShadowBan     = "shadow_ban_user"
+	actionLiftShadowBan = "lift_shadow_ban"
+)
+
+var errModerationTargetNotFound = errors.New("moderation target not found")
+
+// ReportGroup collects the open reports against a single chirp or user.
+type ReportGroup struct {
+	TargetType      string         `json:"target_type"`
+	ChirpID         *uuid.UUID     `json:"chirp_id,omitempty"`
+	UserID          uuid.UUID      `json:"user_id"`
+	ReportCount     int            `json:"report_count"`
+	Reasons         map[string]int `json:"reasons"`
+	FlaggedByFilter bool           `json:"flagged_by_filter"`
+	FirstReportedAt time.Time      `json:"first_reported_at"`
+	Reports         []Report       `json:"reports"`
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ModeratorID *uuid.UUID `json:"moderator_id"`
+	Action      string     `json:"action"`
+	ChirpID     *uuid.UUID `json:"chirp_id,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	Reason      string     `json:"reason"`
+}
+
+// handlerGetModerationQueue lists open reports grouped by what they
+// target, along with chirps flagged by the word filter. Targets with the
+// most reports come first, then the ones waiting longest.
+func (api *apiConfig) handlerGetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateModerator(w, r)
+	if !ok {
+		return
+	}
+	reports, err := api.db.GetOpenReports(r.Context(), moderationQueueReportLimit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get reports")
+		return
+	}
+	flagged, err := api.db.GetFlaggedChirps(r.Context(), moderationQueueFlaggedLimit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get flagged chirps")
+		return
+	}
+
+	groups := []*ReportGroup{}
+	byTarget := map[uuid.UUID]*ReportGroup{}
+	group := func(targetType string, chirpID uuid.NullUUID, userID uuid.UUID, at time.Time) *ReportGroup {
+		key := userID
+		if chirpID.Valid {
+			key = chirpID.UUID
+		}
+		g, ok := byTarget[key]
+		if !ok {
+			g = &ReportGroup{
+				TargetType:      targetType,
+				ChirpID:         nullUUIDPtr(chirpID),
+				UserID:          userID,
+				Reasons:         map[string]int{},
+				FirstReportedAt: at,
+				Reports:         []Report{},
+			}
+			byTarget[key] = g
+			groups = append(groups, g)
+		}
+		return g
+	}
+	for _, report := range reports {
+		g := group(report.TargetType, report.ChirpID, report.UserID.UUID, report.CreatedAt)
+		g.ReportCount++
+		g.Reasons[report.Reason]++
+		g.Reports = append(g.Reports, reportFromDB(report))
+	}
+	for _, chirp := range flagged {
+		g := group("chirp", uuid.NullUUID{UUID: chirp.ID, Valid: true}, chirp.UserID, chirp.FlaggedAt.Time)
+		g.FlaggedByFilter = true
+		if chirp.FlaggedAt.Time.Before(g.FirstReportedAt) {
+			g.FirstReportedAt = chirp.FlaggedAt.Time
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].ReportCount != groups[j].ReportCount {
+			return groups[i].ReportCount > groups[j].ReportCount
+		}
+		return groups[i].FirstReportedAt.Before(groups[j].FirstReportedAt)
+	})
+	respondWithJSON(w, http.StatusOK, groups)
+}
+
+// handlerCreateModerationAction applies a moderator's decision to a chirp
+// or user, resolves the open reports against it and records the action,
+// all in one transaction.
+func (api *apiConfig) handlerCreateModerationAction(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := api.authenticateModerator(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		Action         string     `json:"action"`
+		ChirpID        *uuid.UUID `json:"chirp_id"`
+		UserID         *uuid.UUID `json:"user_id"`
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	switch params.Action {
+	case actionDismiss:
+		if params.ChirpID == nil && params.UserID == nil {
+			respondWithError(w, http.StatusBadRequest, "Dismissing needs a chirp_id or user_id")
+			return
+		}
+	case actionHideChirp, actionRemoveChirp:
+		if params.ChirpID == nil {
+			respondWithError(w, http.StatusBadRequest, "This action needs a chirp_id")
+			return
+		}
+	case actionSuspendUser:
+		if params.ChirpID == nil && params.UserID == nil {
+			respondWithError(w, http.StatusBadRequest, "Suspending needs a user_id or the chirp_id of one of their chirps")
+			return
+		}
+		if params.SuspendedUntil != nil && !params.SuspendedUntil.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future")
+			return
+		}
+	case actionUnsuspendUser, actionShadowBan, actionLiftShadowBan:
+		if params.ChirpID == nil && params.UserID == nil {
+			respondWithError(w, http.StatusBadRequest, "This action needs a user_id or the chirp_id of one of their chirps")
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Unknown moderation action")
+		return
+	}
+
+	var action database.ModerationAction
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		var chirp *database.Chirp
+		var userID uuid.UUID
+		if params.ChirpID != nil {
+			c, err := q.GetChirp(r.Context(), *params.ChirpID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errModerationTargetNotFound
+				}
+				return err
+			}
+			chirp = &c
+			userID = c.UserID
+		}
+		if params.UserID != nil {
+			if chirp != nil && chirp.UserID != *params.UserID {
+				return errModerationTargetNotFound
+			}
+			_, err := q.GetUserByID(r.Context(), *params.UserID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errModerationTargetNotFound
+				}
+				return err
+			}
+			userID = *params.UserID
+		}
+
+		moderator := uuid.NullUUID{UUID: moderatorID, Valid: true}
+		status := "actioned"
+		if params.Action == actionDismiss {
+			status = "dismissed"
+		}
+		// Reports are resolved before the action is applied, since
+		// removing a chirp clears the chirp_id they're found by.
+		//
+		// Lifting a sanction is a decision about an earlier action, not
+		// about anything reported since, so it leaves reports open.
+		resolves := params.Action != actionUnsuspendUser && params.Action != actionLiftShadowBan
+		targetsUser := params.Action == actionSuspendUser || params.Action == actionShadowBan
+		if resolves && chirp != nil {
+			_, err := q.ResolveChirpReports(r.Context(), database.ResolveChirpReportsParams{
+				Status:      status,
+				ModeratorID: moderator,
+				ChirpID:     uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if resolves && (chirp == nil || targetsUser) {
+			_, err := q.ResolveUserReports(r.Context(), database.ResolveUserReportsParams{
+				Status:      status,
+				ModeratorID: moderator,
+				UserID:      userID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		switch params.Action {
+		case actionDismiss:
+			if chirp != nil {
+				err = q.ClearChirpFlag(r.Context(), chirp.ID)
+			}
+		case actionHideChirp:
+			err = q.HideChirp(r.Context(), database.HideChirpParams{
+				HiddenBy: moderator,
+				ID:       chirp.ID,
+			})
+		case actionRemoveChirp:
+			err = q.DeleteChirp(r.Context(), database.DeleteChirpParams{
+				UserID: chirp.UserID,
+				ID:     chirp.ID,
+			})
+		case actionSuspendUser:
+			err = suspendUser(r.Context(), q, userID, params.Reason, params.SuspendedUntil)
+			if err == nil {
+				_, err = audit.Append(r.Context(), q, audit.Event{
+					ActorID:    moderatorID,
+					Action:     audit.ActionSessionsRevoked,
+					TargetType: "user",
+					TargetID:   userID.String(),
+					IP:         clientIP(r),
+					Metadata:   map[string]string{"reason": "suspended"},
+				})
+			}
+		case actionUnsuspendUser:
+			err = q.UnsuspendUser(r.Context(), userID)
+		case actionShadowBan, actionLiftShadowBan:
+			err = q.SetUserShadowBanned(r.Context(), database.SetUserShadowBannedParams{
+				ShadowBanned: params.Action == actionShadowBan,
+				ID:           userID,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		recorded := database.CreateModerationActionParams{
+			ModeratorID: moderator,
+			Action:      params.Action,
+			UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+			Reason:      params.Reason,
+		}
+		if chirp != nil {
+			recorded.ChirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		}
+		action, err = q.CreateModerationAction(r.Context(), recorded)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errModerationTargetNotFound) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp or user")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply moderation action")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, moderationActionFromDB(action))
+}
+
+func (api *apiConfig) handlerGetChirpModerationActions(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateModerator(w, r)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+	actions, err := api.db.GetChirpModerationActions(r.Context(), uuid.NullUUID{UUID: chirpID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get moderation actions")
+		return
+	}
+	respondWithModerationActions(w, actions)
+}
+
+func (api *apiConfig) handlerGetUserModerationActions(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateModerator(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	actions, err := api.db.GetUserModerationActions(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get moderation actions")
+		return
+	}
+	respondWithModerationActions(w, actions)
+}
+
+// suspendUser suspends a user and signs them out everywhere by revoking
+// their refresh tokens. Open streams and WebSockets notice on their next
+// isSuspended check and close.
+func suspendUser(ctx context.Context, q *database.Queries, userID uuid.UUID, reason string, until *time.Time) error {
+	params := database.SuspendUserParams{
+		ID:               userID,
+		SuspensionReason: reason,
+	}
+	if until != nil {
+		params.SuspendedUntil = sql.NullTime{Time: until.UTC(), Valid: true}
+	}
+	err := q.SuspendUser(ctx, params)
+	if err != nil {
+		return err
+	}
+	return q.RevokeUserRefreshTokens(ctx, userID)
+}
+
+func respondWithModerationActions(w http.ResponseWriter, actions []database.ModerationAction) {
+	responseActions := []ModerationAction{}
+	for _, action := range actions {
+		responseActions = append(responseActions, moderationActionFromDB(action))
+	}
+	respondWithJSON(w, http.StatusOK, responseActions)
+}
+
+func moderationActionFromDB(action database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:          action.ID,
+		CreatedAt:   action.CreatedAt,
+		ModeratorID: nullUUIDPtr(action.ModeratorID),
+		Action:      action.Action,
+		ChirpID:     nullUUIDPtr(action.ChirpID),
+		UserID:      nullUUIDPtr(action.UserID),
+		Reason:      action.Reason,
+	}
+}
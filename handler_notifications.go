@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/Madlite/chirpy/internal/jobs"
+	"github.com/google/uuid"
+)
+
+// Kinds of notification.
+const (
+	notificationMention        = "mention"
+	notificationFollow         = "follow"
+	notificationFollowRequest  = "follow_request"
+	notificationFollowAccepted = "follow_accepted"
+	notificationChirpyRed      = "chirpy_red"
+)
+
+const (
+	// notificationActorsShown is how many of a group's most recent actors
+	// are listed; the rest are only counted.
+	notificationActorsShown   = 3
+	readNotificationRetention = 90 * 24 * time.Hour
+)
+
+type Notification struct {
+	ID         uuid.UUID           `json:"id"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+	Kind       string              `json:"kind"`
+	ChirpID    *uuid.UUID          `json:"chirp_id,omitempty"`
+	Actors     []NotificationActor `json:"actors"`
+	ActorCount int64               `json:"actor_count"`
+	Summary    string              `json:"summary"`
+	Read       bool                `json:"read"`
+}
+
+type NotificationActor struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	DisplayName string    `json:"display_name"`
+}
+
+// notification describes one event for createNotification.
+type notification struct {
+	UserID  uuid.UUID
+	Kind    string
+	ChirpID uuid.NullUUID
+	ActorID uuid.NullUUID
+}
+
+// groupKey decides which events collapse into one notification: new
+// followers, follow requests and accepted requests each together, and
+// everything else per chirp.
+func (n notification) groupKey() string {
+	if n.ChirpID.Valid {
+		return n.Kind + ":" + n.ChirpID.UUID.String()
+	}
+	return n.Kind
+}
+
+// createNotification records n, adding its actor to the user's unread
+// notification of the same group if there is one. Pass the transaction
+// that made the change being notified about, so the two commit together.
+func createNotification(ctx context.Context, q *database.Queries, n notification) error {
+	id, err := q.UpsertNotification(ctx, database.UpsertNotificationParams{
+		UserID:   n.UserID,
+		Kind:     n.Kind,
+		GroupKey: n.groupKey(),
+		ChirpID:  n.ChirpID,
+	})
+	if err != nil || !n.ActorID.Valid {
+		return err
+	}
+	return q.AddNotificationActor(ctx, database.AddNotificationActorParams{
+		NotificationID: id,
+		ActorID:        n.ActorID.UUID,
+	})
+}
+
+type mentionJob struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// enqueueMentionNotifications queues a job to notify the users mentioned
+// in a newly published chirp. q should be the transaction that published
+// it. Finding who may see the chirp takes a query per chirp, so it's left
+// to the job rather than slowing down publishing.
+func enqueueMentionNotifications(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if len(mentionedUsernames(chirp.Body)) == 0 {
+		return nil
+	}
+	_, err := jobs.Enqueue(ctx, q, jobs.Job{
+		Kind:      jobNotifyMentions,
+		Payload:   mentionJob{ChirpID: chirp.ID},
+		UniqueKey: jobNotifyMentions + ":" + chirp.ID.String(),
+	})
+	return err
+}
+
+// handleNotifyMentionsJob notifies the users mentioned in a chirp who are
+// allowed to see it.
+func (api *apiConfig) handleNotifyMentionsJob(ctx context.Context, payload json.RawMessage) error {
+	var job mentionJob
+	err := json.Unmarshal(payload, &job)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	chirp, err := api.db.GetChirp(ctx, job.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted before anyone was told about it.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	recipients, err := api.db.GetMentionRecipients(ctx, database.GetMentionRecipientsParams{
+		ChirpID:   chirp.ID,
+		Usernames: mentionedUsernames(chirp.Body),
+	})
+	if err != nil {
+		return err
+	}
+	return api.withTx(ctx, func(q *database.Queries) error {
+		for _, userID := range recipients {
+			err := createNotification(ctx, q, notification{
+				UserID:  userID,
+				Kind:    notificationMention,
+				ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+				ActorID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// handlerGetNotifications lists the caller's notifications, most recently
+// active first, along with how many are unread. ?unread=true leaves out
+// read ones; pages are requested with ?limit=, ?before= and ?before_id=,
+// before being the updated_at of the last notification. A notification
+// that gets a new actor while the client is paging moves to the top, so
+// it can be missed by later pages or, if the client starts again, seen
+// twice; clients should merge notifications by ID.
+func (api *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	limit, before, beforeID, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	notifications, err := api.db.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		Before:     before,
+		BeforeID:   beforeID,
+		PageSize:   limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notifications")
+		return
+	}
+	responseNotifications, err := api.notificationsWithActors(r.Context(), userID, notifications)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get notification actors")
+		return
+	}
+	unread, err := api.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count unread notifications")
+		return
+	}
+
+	type response struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+	}
+	respondWithJSON(w, http.StatusOK, response{
+		Notifications: responseNotifications,
+		UnreadCount:   unread,
+	})
+}
+
+func (api *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+	n, err := api.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notification read")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find notification")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerMarkNotificationsRead marks the given notifications read, or all
+// of the caller's notifications if no IDs are given.
+func (api *apiConfig) handlerMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	type parameters struct {
+		NotificationIDs []uuid.UUID `json:"notification_ids"`
+	}
+	params := parameters{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err = decoder.Decode(&params)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+			return
+		}
+	}
+
+	if len(params.NotificationIDs) == 0 {
+		err = api.db.MarkAllNotificationsRead(r.Context(), userID)
+	} else {
+		err = api.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			UserID: userID,
+			Ids:    params.NotificationIDs,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notifications read")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) notificationsWithActors(ctx context.Context, userID uuid.UUID, notifications []database.GetNotificationsRow) ([]Notification, error) {
+	ids := make([]uuid.UUID, 0, len(notifications))
+	for _, n := range notifications {
+		ids = append(ids, n.Notification.ID)
+	}
+	actors, err := api.db.GetNotificationActors(ctx, database.GetNotificationActorsParams{
+		NotificationIds: ids,
+		UserID:          userID,
+		PerNotification: notificationActorsShown,
+	})
+	if err != nil {
+		return nil, err
+	}
+	byNotification := map[uuid.UUID][]NotificationActor{}
+	for _, a := range actors {
+		byNotification[a.NotificationID] = append(byNotification[a.NotificationID], NotificationActor{
+			UserID:      a.ActorID,
+			Username:    a.Username.String,
+			DisplayName: a.DisplayName,
+		})
+	}
+
+	responseNotifications := []Notification{}
+	for _, n := range notifications {
+		notification := Notification{
+			ID:         n.Notification.ID,
+			CreatedAt:  n.Notification.CreatedAt,
+			UpdatedAt:  n.Notification.UpdatedAt,
+			Kind:       n.Notification.Kind,
+			Actors:     byNotification[n.Notification.ID],
+			ActorCount: n.ActorCount,
+			Read:       n.Notification.ReadAt.Valid,
+		}
+		if notification.Actors == nil {
+			notification.Actors = []NotificationActor{}
+		}
+		if n.Notification.ChirpID.Valid {
+			chirpID := n.Notification.ChirpID.UUID
+			notification.ChirpID = &chirpID
+		}
+		notification.Summary = notificationSummary(notification)
+		responseNotifications = append(responseNotifications, notification)
+	}
+	return responseNotifications, nil
+}
+
+// notificationSummary describes n in a sentence, naming its most recent
+// actor and counting the others.
+func notificationSummary(n Notification) string {
+	who := "Someone"
+	if len(n.Actors) > 0 {
+		who = n.Actors[0].name()
+	}
+	switch others := n.ActorCount - 1; {
+	case others == 1:
+		who += " and 1 other"
+	case others > 1:
+		who += fmt.Sprintf(" and %d others", others)
+	}
+
+	switch n.Kind {
+	case notificationMention:
+		return who + " mentioned you"
+	case notificationFollow:
+		return who + " followed you"
+	case notificationFollowRequest:
+		return who + " asked to follow you"
+	case notificationFollowAccepted:
+		return who + " accepted your follow request"
+	case notificationChirpyRed:
+		return "You're now a Chirpy Red member"
+	default:
+		return ""
+	}
+}
+
+func (a NotificationActor) name() string {
+	if a.DisplayName != "" {
+		return a.DisplayName
+	}
+	if a.Username != "" {
+		return "@" + a.Username
+	}
+	return "Someone"
+}
+
+// purgeReadNotifications removes notifications read long ago.
+func (api *apiConfig) purgeReadNotifications(ctx context.Context) {
+	_, err := api.db.PurgeReadNotifications(ctx, time.Now().Add(-readNotificationRetention))
+	if err != nil {
+		log.Printf("Error purging read notifications: %s", err)
+	}
+}
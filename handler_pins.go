@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Chirpy Red members may pin more chirps to their profile.
+const (
+	maxPinnedChirps    = 3
+	maxPinnedChirpsRed = 10
+)
+
+func (api *apiConfig) handlerPinChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirp, ok := api.ownChirpForRequest(w, r)
+	if !ok {
+		return
+	}
+	if chirp.PinnedAt.Valid {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// PinChirp counts the user's pins and pins the chirp in one statement,
+	// but two of them running at once can both count below the limit.
+	// Locking the user row makes concurrent pins by the same user take
+	// turns.
+	var n int64
+	err := api.withTx(r.Context(), func(q *database.Queries) error {
+		user, err := q.GetUserByIDForUpdate(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		maxPins := maxPinnedChirps
+		if user.IsChirpyRed {
+			maxPins = maxPinnedChirpsRed
+		}
+		n, err = q.PinChirp(r.Context(), database.PinChirpParams{
+			ID:      chirp.ID,
+			UserID:  userID,
+			MaxPins: int32(maxPins),
+		})
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusConflict, "Pinned chirp limit reached")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerUnpinChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirp, ok := api.ownChirpForRequest(w, r)
+	if !ok {
+		return
+	}
+	_, err := api.db.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		ID:     chirp.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unpin chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownChirpForRequest authenticates the caller and loads the {chirpID}
+// chirp, which the caller must have written.
+func (api *apiConfig) ownChirpForRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Chirp, bool) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	chirp, err := api.db.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "Not owner of chirp")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	return userID, chirp, true
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+// Poll is the poll attached to a chirp. Vote counts are only filled in
+// once the viewer has voted or the poll has closed.
+type Poll struct {
+	ID         uuid.UUID    `json:"id"`
+	ClosesAt   time.Time    `json:"closes_at"`
+	Closed     bool         `json:"closed"`
+	Options    []PollOption `json:"options"`
+	TotalVotes *int64       `json:"total_votes,omitempty"`
+	MyVote     *uuid.UUID   `json:"my_vote,omitempty"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+// pollParameters is the poll payload accepted by handlerCreateChirp.
+type pollParameters struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+// validate checks the shape of the poll payload and trims the option text
+// in place.
+func (p *pollParameters) validate() error {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return fmt.Errorf("Poll must have between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	seen := make(map[string]bool, len(p.Options))
+	for i, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return errors.New("Poll options can't be empty")
+		}
+		if utf8.RuneCountInString(option) > maxPollOptionLength {
+			return errors.New("Poll option is too long")
+		}
+		key := strings.ToLower(option)
+		if seen[key] {
+			return errors.New("Poll options must be unique")
+		}
+		seen[key] = true
+		p.Options[i] = option
+	}
+
+	now := time.Now()
+	if p.ClosesAt.Before(now.Add(minPollDuration)) {
+		return fmt.Errorf("Poll must stay open for at least %s", minPollDuration)
+	}
+	if p.ClosesAt.After(now.Add(maxPollDuration)) {
+		return fmt.Errorf("Poll can't stay open for more than %s", maxPollDuration)
+	}
+	return nil
+}
+
+// moderatePoll runs the poll options through the word filter, reporting
+// whether the chirp should be flagged for moderation.
+func (api *apiConfig) moderatePoll(p *pollParameters) (bool, error) {
+	flagged := false
+	for i, option := range p.Options {
+		cleaned, optionFlagged, err := api.moderateText(option)
+		if err != nil {
+			return false, err
+		}
+		p.Options[i] = cleaned
+		flagged = flagged || optionFlagged
+	}
+	return flagged, nil
+}
+
+// createPoll stores poll for a chirp that is being created with q.
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, poll pollParameters) error {
+	dbPoll, err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: poll.ClosesAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	for i, option := range poll.Options {
+		_, err = q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   dbPoll.ID,
+			Position: int32(i),
+			Text:     option,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlerVotePoll records the caller's vote on a chirp's poll, replacing
+// any earlier vote. Votes are rejected once the poll has closed.
+func (api *apiConfig) handlerVotePoll(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+	type parameters struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	chirp, err := api.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+	poll, err := api.db.GetPollByChirp(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp doesn't have a poll")
+		return
+	}
+	n, err := api.db.CastPollVote(r.Context(), database.CastPollVoteParams{
+		UserID:   userID,
+		PollID:   poll.ID,
+		OptionID: params.OptionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote")
+		return
+	}
+	if n == 0 {
+		if !time.Now().Before(poll.ClosesAt) {
+			respondWithError(w, http.StatusConflict, "Poll is closed")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid poll option")
+		return
+	}
+
+	responseChirps := []Chirp{chirpFromDB(chirp)}
+	err = api.decorateChirps(r.Context(), userID, responseChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get poll")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, responseChirps[0].Poll)
+}
+
+// attachPolls loads the polls, tallies and the viewer's own votes for
+// chirps in a fixed number of queries.
+func (api *apiConfig) attachPolls(ctx context.Context, viewerID uuid.UUID, chirps []Chirp) error {
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+	polls, err := api.db.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	if len(polls) == 0 {
+		return nil
+	}
+	pollIDs := make([]uuid.UUID, 0, len(polls))
+	for _, poll := range polls {
+		pollIDs = append(pollIDs, poll.ID)
+	}
+
+	tallies, err := api.db.GetPollOptionTallies(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+	myVotes := map[uuid.UUID]uuid.UUID{}
+	if viewerID != uuid.Nil {
+		votes, err := api.db.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+			UserID:  viewerID,
+			PollIds: pollIDs,
+		})
+		if err != nil {
+			return err
+		}
+		for _, vote := range votes {
+			myVotes[vote.PollID] = vote.OptionID
+		}
+	}
+
+	now := time.Now()
+	byID := make(map[uuid.UUID]*Poll, len(polls))
+	byChirp := make(map[uuid.UUID]*Poll, len(polls))
+	for _, poll := range polls {
+		p := &Poll{
+			ID:       poll.ID,
+			ClosesAt: poll.ClosesAt,
+			Closed:   !now.Before(poll.ClosesAt),
+			Options:  []PollOption{},
+		}
+		if optionID, ok := myVotes[poll.ID]; ok {
+			p.MyVote = &optionID
+		}
+		if p.Closed || p.MyVote != nil {
+			p.TotalVotes = new(int64)
+		}
+		byID[poll.ID] = p
+		byChirp[poll.ChirpID] = p
+	}
+	for _, tally := range tallies {
+		p := byID[tally.PollID]
+		option := PollOption{
+			ID:   tally.ID,
+			Text: tally.Text,
+		}
+		if p.TotalVotes != nil {
+			votes := tally.Votes
+			option.Votes = &votes
+			*p.TotalVotes += votes
+		}
+		p.Options = append(p.Options, option)
+	}
+	for i := range chirps {
+		chirps[i].Poll = byChirp[chirps[i].ID]
+	}
+	return nil
+}
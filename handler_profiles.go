@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/blob"
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxLocationLength    = 30
+	maxWebsiteLength     = 100
+	maxAvatarSize        = 1 << 20
+)
+
+// imageExtensions maps the image types accepted for uploads to the file
+// extension they are stored under.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Profile is the public view of a user. It must never carry the email or
+// password hash.
+type Profile struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Username       string    `json:"username,omitempty"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	Location       string    `json:"location"`
+	Website        string    `json:"website"`
+	AvatarURL      string    `json:"avatar_url,omitempty"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	IsProtected    bool      `json:"is_protected"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	ChirpCount     int64     `json:"chirp_count"`
+}
+
+func (api *apiConfig) handlerGetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	profile, err := api.db.GetPublicProfile(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, profileFromDB(profile))
+}
+
+func (api *apiConfig) handlerUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	if !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	type parameters struct {
+		DisplayName string `json:"display_name"`
+		Bio         string `json:"bio"`
+		Location    string `json:"location"`
+		Website     string `json:"website"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	switch {
+	case utf8.RuneCountInString(params.DisplayName) > maxDisplayNameLength:
+		respondWithError(w, http.StatusBadRequest, "Display name is too long")
+		return
+	case utf8.RuneCountInString(params.Bio) > maxBioLength:
+		respondWithError(w, http.StatusBadRequest, "Bio is too long")
+		return
+	case utf8.RuneCountInString(params.Location) > maxLocationLength:
+		respondWithError(w, http.StatusBadRequest, "Location is too long")
+		return
+	case len(params.Website) > maxWebsiteLength:
+		respondWithError(w, http.StatusBadRequest, "Website is too long")
+		return
+	}
+	if params.Website != "" {
+		u, err := url.Parse(params.Website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			respondWithError(w, http.StatusBadRequest, "Website must be an http or https URL")
+			return
+		}
+	}
+
+	_, err = api.db.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		ID:          userID,
+		DisplayName: params.DisplayName,
+		Bio:         params.Bio,
+		Location:    params.Location,
+		Website:     params.Website,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update profile")
+		return
+	}
+	profile, err := api.db.GetPublicProfile(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get profile")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, profileFromDB(profile))
+}
+
+// handlerUploadAvatar stores the raw request body as the caller's avatar.
+// The declared Content-Type must be an accepted image type and agree with
+// the sniffed content.
+func (api *apiConfig) handlerUploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	if !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Avatar must be a PNG, JPEG, GIF or WebP image")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAvatarSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Avatar is too large")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Couldn't read avatar")
+		return
+	}
+	if http.DetectContentType(data) != contentType {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Avatar content doesn't match its Content-Type")
+		return
+	}
+
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	key := fmt.Sprintf("avatars/%s-%s%s", userID, uuid.New(), ext)
+	err = api.blobs.Put(r.Context(), key, bytes.NewReader(data))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store avatar")
+		return
+	}
+	err = api.db.UpdateUserAvatar(r.Context(), database.UpdateUserAvatarParams{
+		ID:        userID,
+		AvatarKey: sql.NullString{String: key, Valid: true},
+	})
+	if err != nil {
+		api.blobs.Delete(r.Context(), key)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update avatar")
+		return
+	}
+	if user.AvatarKey.Valid {
+		err = api.blobs.Delete(r.Context(), user.AvatarKey.String)
+		if err != nil {
+			log.Printf("Error deleting old avatar %s: %s", user.AvatarKey.String, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiConfig) handlerGetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	profile, err := api.db.GetPublicProfile(r.Context(), userID)
+	if err != nil || !profile.AvatarKey.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find avatar")
+		return
+	}
+	rc, err := api.blobs.Open(r.Context(), profile.AvatarKey.String)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find avatar")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't open avatar")
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(profile.AvatarKey.String)))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, rc)
+}
+
+func (api *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "Can't follow yourself")
+		return
+	}
+	_, err = api.db.GetPublicProfile(r.Context(), followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	blocked, err := api.db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserA: userID,
+		UserB: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check blocks")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "Can't follow this user")
+		return
+	}
+	var follow database.Follow
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		n, err := q.CreateFollow(r.Context(), database.CreateFollowParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+		if err != nil {
+			return err
+		}
+		follow, err = q.GetFollow(r.Context(), database.GetFollowParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+		if err != nil || n == 0 {
+			// Already following or asked to, so they've been told.
+			return err
+		}
+		kind := notificationFollow
+		if follow.Status == "pending" {
+			kind = notificationFollowRequest
+		}
+		return createNotification(r.Context(), q, notification{
+			UserID:  followeeID,
+			Kind:    kind,
+			ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// A block landed after the check above, so nothing was inserted.
+		respondWithError(w, http.StatusForbidden, "Can't follow this user")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow user")
+		return
+	}
+
+	// Follows of protected accounts stay pending until the account owner
+	// approves them.
+	type response struct {
+		Status string `json:"status"`
+	}
+	code := http.StatusOK
+	if follow.Status == "pending" {
+		code = http.StatusAccepted
+	}
+	respondWithJSON(w, code, response{
+		Status: follow.Status,
+	})
+}
+
+func (api *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	err = api.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func profileFromDB(profile database.GetPublicProfileRow) Profile {
+	p := Profile{
+		ID:             profile.ID,
+		CreatedAt:      profile.CreatedAt,
+		Username:       profile.Username.String,
+		DisplayName:    profile.DisplayName,
+		Bio:            profile.Bio,
+		Location:       profile.Location,
+		Website:        profile.Website,
+		IsChirpyRed:    profile.IsChirpyRed,
+		IsProtected:    profile.IsProtected,
+		FollowerCount:  profile.FollowerCount,
+		FollowingCount: profile.FollowingCount,
+		ChirpCount:     profile.ChirpCount,
+	}
+	if profile.AvatarKey.Valid {
+		p.AvatarURL = fmt.Sprintf("/api/users/%s/avatar", profile.ID)
+	}
+	return p
+}
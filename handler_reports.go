@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxReportDetailsLength = 500
+
+var reportReasons = []string{
+	"spam",
+	"harassment",
+	"hate",
+	"violence",
+	"sexual",
+	"self_harm",
+	"misinformation",
+	"impersonation",
+	"other",
+}
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	TargetType string     `json:"target_type"`
+	ChirpID    *uuid.UUID `json:"chirp_id,omitempty"`
+	UserID     *uuid.UUID `json:"user_id,omitempty"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+}
+
+func (api *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+	reason, details, ok := decodeReport(w, r)
+	if !ok {
+		return
+	}
+	chirp, err := api.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+	if chirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "Can't report your own chirp")
+		return
+	}
+
+	report, err := api.db.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: userID,
+		TargetType: "chirp",
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		UserID:     uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		Reason:     reason,
+		Details:    details,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "You've already reported this chirp")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+func (api *apiConfig) handlerReportUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "Can't report yourself")
+		return
+	}
+	reason, details, ok := decodeReport(w, r)
+	if !ok {
+		return
+	}
+	_, err = api.db.GetPublicProfile(r.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+
+	report, err := api.db.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: userID,
+		TargetType: "user",
+		UserID:     uuid.NullUUID{UUID: targetID, Valid: true},
+		Reason:     reason,
+		Details:    details,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "You've already reported this user")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+func decodeReport(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return "", "", false
+	}
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, "Unknown report reason")
+		return "", "", false
+	}
+	if utf8.RuneCountInString(params.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Report details are too long")
+		return "", "", false
+	}
+	return params.Reason, params.Details, true
+}
+
+func reportFromDB(report database.Report) Report {
+	return Report{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		ReporterID: report.ReporterID,
+		TargetType: report.TargetType,
+		ChirpID:    nullUUIDPtr(report.ChirpID),
+		UserID:     nullUUIDPtr(report.UserID),
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxScheduleAhead        = 365 * 24 * time.Hour
+	scheduledChirpBatchSize = 100
+
+	defaultScheduledChirpPollInterval = 15 * time.Second
+)
+
+type ScheduledChirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
+// scheduleChirp stores an already validated chirp body to be published at
+// publishAt instead of creating it straight away.
+func (api *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, userID uuid.UUID, body string, flagged bool, publishAt time.Time) {
+	if !validPublishAt(w, publishAt) {
+		return
+	}
+	scheduled, err := api.db.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+		UserID:    userID,
+		Body:      body,
+		PublishAt: publishAt.UTC(),
+		Flagged:   flagged,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp")
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, scheduledChirpFromDB(scheduled))
+}
+
+func (api *apiConfig) handlerGetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	scheduled, err := api.db.GetScheduledChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get scheduled chirps")
+		return
+	}
+	responseChirps := []ScheduledChirp{}
+	for _, s := range scheduled {
+		responseChirps = append(responseChirps, scheduledChirpFromDB(s))
+	}
+	respondWithJSON(w, http.StatusOK, responseChirps)
+}
+
+func (api *apiConfig) handlerRescheduleChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	scheduledID, err := uuid.Parse(r.PathValue("scheduledID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid scheduled chirp ID")
+		return
+	}
+	type parameters struct {
+		PublishAt time.Time `json:"publish_at"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if !validPublishAt(w, params.PublishAt) {
+		return
+	}
+	// Once the publisher has picked the chirp up the row is gone, so a
+	// reschedule that loses that race gets a 404 rather than silently
+	// moving an already published chirp.
+	scheduled, err := api.db.RescheduleChirp(r.Context(), database.RescheduleChirpParams{
+		ID:        scheduledID,
+		UserID:    userID,
+		PublishAt: params.PublishAt.UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find scheduled chirp")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, scheduledChirpFromDB(scheduled))
+}
+
+func (api *apiConfig) handlerCancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	scheduledID, err := uuid.Parse(r.PathValue("scheduledID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid scheduled chirp ID")
+		return
+	}
+	n, err := api.db.CancelScheduledChirp(r.Context(), database.CancelScheduledChirpParams{
+		ID:     scheduledID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't cancel scheduled chirp")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find scheduled chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishScheduledChirps moves every due scheduled chirp into the chirps
+// table. Each batch is claimed and published in a single statement using
+// FOR UPDATE SKIP LOCKED, and the published chirp reuses the scheduled
+// chirp's ID, so concurrent instances can never publish one twice.
+func (api *apiConfig) publishScheduledChirps(ctx context.Context) {
+	for {
+		var published []database.Chirp
+		err := api.withTx(ctx, func(q *database.Queries) error {
+			var err error
+			published, err = q.PublishDueChirps(ctx, scheduledChirpBatchSize)
+			if err != nil {
+				return err
+			}
+			for _, chirp := range published {
+				err = enqueueMentionNotifications(ctx, q, chirp)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error publishing scheduled chirps: %s", err)
+			return
+		}
+		if len(published) < scheduledChirpBatchSize {
+			return
+		}
+	}
+}
+
+func validPublishAt(w http.ResponseWriter, publishAt time.Time) bool {
+	now := time.Now()
+	if !publishAt.After(now) {
+		respondWithError(w, http.StatusBadRequest, "publish_at must be in the future")
+		return false
+	}
+	if publishAt.After(now.Add(maxScheduleAhead)) {
+		respondWithError(w, http.StatusBadRequest, "publish_at is too far in the future")
+		return false
+	}
+	return true
+}
+
+func scheduledChirpFromDB(scheduled database.ScheduledChirp) ScheduledChirp {
+	return ScheduledChirp{
+		ID:        scheduled.ID,
+		CreatedAt: scheduled.CreatedAt,
+		UpdatedAt: scheduled.UpdatedAt,
+		Body:      scheduled.Body,
+		UserID:    scheduled.UserID,
+		PublishAt: scheduled.PublishAt,
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxContentWarningLength = 100
+
+// Ways a viewer can choose to see sensitive chirps.
+const (
+	sensitiveMediaHide     = "hide"
+	sensitiveMediaCollapse = "collapse"
+	sensitiveMediaShow     = "show"
+)
+
+var errContentWarningTooLong = errors.New("Content warning is too long")
+
+func (api *apiConfig) cleanContentWarning(cw string) (string, bool, error) {
+	cw = strings.TrimSpace(cw)
+	if utf8.RuneCountInString(cw) > maxContentWarningLength {
+		return "", false, errContentWarningTooLong
+	}
+	return api.moderateText(cw)
+}
+
+// handlerEditChirp replaces the body, content warning and sensitive flag
+// of one of the caller's chirps. A sensitive flag forced by a moderator
+// can't be cleared by the author.
+func (api *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirp, ok := api.ownChirpForRequest(w, r)
+	if !ok || !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	type parameters struct {
+		Body           string `json:"body"`
+		ContentWarning string `json:"content_warning"`
+		Sensitive      bool   `json:"sensitive"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	body, flagged, err := api.cleanChirpBody(params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cw, cwFlagged, err := api.cleanContentWarning(params.ContentWarning)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updated, err := api.db.UpdateChirp(r.Context(), database.UpdateChirpParams{
+		Body:           body,
+		ContentWarning: cw,
+		Sensitive:      params.Sensitive,
+		Flagged:        flagged || cwFlagged,
+		ID:             chirp.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp")
+		return
+	}
+	responseChirps := []Chirp{chirpFromDB(updated)}
+	err = api.decorateChirps(r.Context(), userID, responseChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, responseChirps[0])
+}
+
+// handlerForceChirpSensitive lets moderators mark anyone's chirp as
+// sensitive, or lift a flag they forced earlier.
+func (api *apiConfig) handlerForceChirpSensitive(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := api.authenticateModerator(w, r)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+	type parameters struct {
+		Sensitive bool `json:"sensitive"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	var chirp database.Chirp
+	if params.Sensitive {
+		chirp, err = api.db.ForceChirpSensitive(r.Context(), database.ForceChirpSensitiveParams{
+			ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+			ID:          chirpID,
+		})
+	} else {
+		chirp, err = api.db.ClearForcedChirpSensitive(r.Context(), chirpID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirpFromDB(chirp))
+}
+
+// handlerUpdatePreferences sets how sensitive chirps are shown to the
+// caller: left out of listings, collapsed behind a warning, or shown.
+func (api *apiConfig) handlerUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	type parameters struct {
+		SensitiveMedia string `json:"sensitive_media"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	switch params.SensitiveMedia {
+	case sensitiveMediaHide, sensitiveMediaCollapse, sensitiveMediaShow:
+	default:
+		respondWithError(w, http.StatusBadRequest, "sensitive_media must be one of hide, collapse or show")
+		return
+	}
+
+	err = api.db.UpdateUserSensitiveMedia(r.Context(), database.UpdateUserSensitiveMediaParams{
+		ID:             userID,
+		SensitiveMedia: params.SensitiveMedia,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update preferences")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, params)
+}
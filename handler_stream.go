@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/Madlite/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	streamBufferSize     = 64
+	streamReplayPageSize = 100
+	streamReplayMargin   = 100
+	streamKeepAlive      = 30 * time.Second
+	chirpEventRetention  = 24 * time.Hour
+)
+
+// handlerStreamChirps pushes chirps to the client as Server-Sent Events as
+// they're created, edited and deleted. ?author_id= limits the stream to one
+// author. A client reconnecting with Last-Event-ID first receives what it
+// missed, as far back as chirpEventRetention.
+//
+// Event IDs are taken when a change is written, not when it commits, so an
+// event can commit after one with a higher ID. To catch those, the replay
+// starts streamReplayMargin events before Last-Event-ID. A reconnecting
+// client may get some events twice and should apply them idempotently.
+//
+// Each event is checked against what the viewer may see, the same as
+// GET /api/chirps, so the stream never reveals more than the listing. A
+// signed-in viewer's stream ends if they are suspended.
+func (api *apiConfig) handlerStreamChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID := api.viewerID(r)
+	var authorID uuid.NullUUID
+	if v := r.URL.Query().Get("author_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	var lastEventID int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+	if viewerID != uuid.Nil {
+		isSuspended, err := api.isSuspended(r.Context(), viewerID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't find user")
+			return
+		}
+		if isSuspended {
+			respondWithError(w, http.StatusForbidden, "Account is suspended")
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming isn't supported")
+		return
+	}
+
+	// Subscribe before replaying so nothing committed in between is lost.
+	// Events can then arrive both ways; replayed ones are skipped live.
+	sub := api.chirpEvents.Subscribe(streamBufferSize)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx := r.Context()
+	replayed := map[int64]bool{}
+	if lastEventID > 0 {
+		params := database.GetChirpEventsAfterParams{
+			AfterID:  max(lastEventID-streamReplayMargin, 0),
+			AuthorID: authorID,
+			PageSize: streamReplayPageSize,
+		}
+		for {
+			events, err := api.db.GetChirpEventsAfter(ctx, params)
+			if err != nil {
+				log.Printf("Error replaying chirp events: %s", err)
+				return
+			}
+			for _, e := range events {
+				replayed[e.ID] = true
+				err = api.writeChirpEvent(ctx, w, viewerID, stream.Event{
+					ID:        e.ID,
+					CreatedAt: e.CreatedAt,
+					Kind:      e.Kind,
+					ChirpID:   e.ChirpID,
+					UserID:    e.UserID,
+				})
+				if err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if len(events) < streamReplayPageSize {
+				break
+			}
+			params.AfterID = events[len(events)-1].ID
+		}
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects
+				// with Last-Event-ID and catches up.
+				return
+			}
+			if replayed[e.ID] || (authorID.Valid && e.UserID != authorID.UUID) {
+				continue
+			}
+			err := api.writeChirpEvent(ctx, w, viewerID, e)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if viewerID != uuid.Nil {
+				isSuspended, err := api.isSuspended(ctx, viewerID)
+				if err != nil {
+					log.Printf("Error checking suspension of %s: %s", viewerID, err)
+				} else if isSuspended {
+					return
+				}
+			}
+			_, err := fmt.Fprint(w, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeChirpEvent writes e as a Server-Sent Event, unless the viewer isn't
+// allowed to know about it.
+func (api *apiConfig) writeChirpEvent(ctx context.Context, w http.ResponseWriter, viewerID uuid.UUID, e stream.Event) error {
+	payload, ok, err := api.chirpEventPayload(ctx, viewerID, e)
+	if err != nil || !ok {
+		return err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: chirp.%s\ndata: %s\n\n", e.ID, e.Kind, data)
+	return err
+}
+
+// chirpEventPayload returns what viewerID should be told about e, and
+// false if they aren't allowed to know about it. Created and updated
+// chirps are sent in full, as a Chirp; deletions send a ChirpTombstone,
+// and are also used for chirps a moderator hid.
+func (api *apiConfig) chirpEventPayload(ctx context.Context, viewerID uuid.UUID, e stream.Event) (any, bool, error) {
+	chirp, err := api.db.GetListedChirp(ctx, database.GetListedChirpParams{
+		ID:       e.ChirpID,
+		ViewerID: viewerID,
+	})
+	switch {
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return nil, false, err
+	case e.Kind == stream.KindDeleted:
+		if err == nil {
+			// Still visible to this viewer, e.g. their own hidden chirp.
+			return nil, false, nil
+		}
+		tell, err := stream.TellDeleted(ctx, api.db, viewerID, e)
+		if err != nil || !tell {
+			return nil, false, err
+		}
+		return ChirpTombstone{
+			ID:        e.ChirpID,
+			Deleted:   true,
+			DeletedAt: e.CreatedAt,
+		}, true, nil
+	case err != nil:
+		// Gone since, or not visible to this viewer.
+		return nil, false, nil
+	}
+	chirps := []Chirp{chirpFromDB(chirp)}
+	err = api.decorateChirps(ctx, viewerID, chirps)
+	if err != nil {
+		return nil, false, err
+	}
+	return chirps[0], true, nil
+}
+
+// purgeChirpEvents removes chirp events too old to be replayed.
+func (api *apiConfig) purgeChirpEvents(ctx context.Context) {
+	_, err := api.db.PurgeChirpEvents(ctx, time.Now().Add(-chirpEventRetention))
+	if err != nil {
+		log.Printf("Error purging chirp events: %s", err)
+	}
+}
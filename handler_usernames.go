@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Madlite/chirpy/internal/database"
+)
+
+// Usernames are 3 to 15 letters, digits or underscores.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,15}$`)
+
+// mentionPattern finds @username mentions. The @ must not follow a word
+// character, so email addresses aren't mistaken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_]{3,15})\b`)
+
+// handlerUpdateUsername sets or, given an empty username, clears the
+// caller's username.
+func (api *apiConfig) handlerUpdateUsername(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	if !api.ensureCanPost(w, r, userID) {
+		return
+	}
+	type parameters struct {
+		Username string `json:"username"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	if params.Username != "" && !usernamePattern.MatchString(params.Username) {
+		respondWithError(w, http.StatusBadRequest, "Username must be 3 to 15 letters, digits or underscores")
+		return
+	}
+
+	err = api.db.UpdateUsername(r.Context(), database.UpdateUsernameParams{
+		ID:       userID,
+		Username: sql.NullString{String: params.Username, Valid: params.Username != ""},
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "That username is taken")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update username")
+		return
+	}
+	profile, err := api.db.GetPublicProfile(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get profile")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, profileFromDB(profile))
+}
+
+// mentionedUsernames returns the distinct usernames mentioned in body,
+// lowercased.
+func mentionedUsernames(body string) []string {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.ToLower(m[1])
+		if !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
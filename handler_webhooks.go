@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/Madlite/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	maxWebhookEndpoints      = 10
+	webhookTimeout           = 10 * time.Second
+	webhookPollInterval      = 5 * time.Second
+	webhookBatchSize         = 20
+	webhookMaxAttempts       = 12
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+
+	// webhookDeliveryLease is how long a claimed delivery is left alone
+	// before another worker may assume this one died and retry it.
+	webhookDeliveryLease = 5 * time.Minute
+
+	// An endpoint is disabled once it has failed at least
+	// webhookDisableAfterFailures deliveries in a row over at least
+	// webhookDisableAfter.
+	webhookDisableAfterFailures = 10
+	webhookDisableAfter         = 24 * time.Hour
+)
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	URL                 string     `json:"url"`
+	EventTypes          []string   `json:"event_types"`
+	Global              bool       `json:"global"`
+	Enabled             bool       `json:"enabled"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID        `json:"id"`
+	CreatedAt     time.Time        `json:"created_at"`
+	EventType     string           `json:"event_type"`
+	Payload       json.RawMessage  `json:"payload"`
+	Status        string           `json:"status"`
+	Attempts      int32            `json:"attempts"`
+	ResponseCode  *int32           `json:"response_code"`
+	NextAttemptAt *time.Time       `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time       `json:"delivered_at,omitempty"`
+	Log           []WebhookAttempt `json:"log"`
+}
+
+type WebhookAttempt struct {
+	AttemptedAt  time.Time `json:"attempted_at"`
+	ResponseCode *int32    `json:"response_code"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int32     `json:"duration_ms"`
+}
+
+// handlerCreateWebhookEndpoint registers an HTTPS endpoint for events
+// about the caller's account. Admins may set global to receive every
+// user's events. The response carries the signing secret, which isn't
+// shown again.
+func (api *apiConfig) handlerCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	type parameters struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+		Global     bool     `json:"global"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	eventTypes, ok := validateWebhookParams(w, params.URL, params.EventTypes)
+	if !ok {
+		return
+	}
+	if params.Global {
+		user, err := api.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user")
+			return
+		}
+		if user.Role != roleAdmin {
+			respondWithError(w, http.StatusForbidden, "Only admins can register global webhooks")
+			return
+		}
+	}
+	count, err := api.db.CountWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count webhooks")
+		return
+	}
+	if count >= maxWebhookEndpoints {
+		respondWithError(w, http.StatusConflict, "Too many webhooks")
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook secret")
+		return
+	}
+	endpoint, err := api.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:     userID,
+		Url:        params.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Global:     params.Global,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook")
+		return
+	}
+	response := webhookEndpointFromDB(endpoint)
+	response.Secret = endpoint.Secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (api *apiConfig) handlerGetWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return
+	}
+	endpoints, err := api.db.GetWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get webhooks")
+		return
+	}
+	responseEndpoints := []WebhookEndpoint{}
+	for _, endpoint := range endpoints {
+		responseEndpoints = append(responseEndpoints, webhookEndpointFromDB(endpoint))
+	}
+	respondWithJSON(w, http.StatusOK, responseEndpoints)
+}
+
+func (api *apiConfig) handlerGetWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := api.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEndpointFromDB(endpoint))
+}
+
+// handlerUpdateWebhookEndpoint changes an endpoint's URL and event types,
+// and enables or disables it. Enabling an endpoint clears its failures;
+// deliveries still pending resume.
+func (api *apiConfig) handlerUpdateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, endpoint, ok := api.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		URL        *string  `json:"url"`
+		EventTypes []string `json:"event_types"`
+		Enabled    *bool    `json:"enabled"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	url := endpoint.Url
+	if params.URL != nil {
+		url = *params.URL
+	}
+	eventTypes := endpoint.EventTypes
+	if params.EventTypes != nil {
+		eventTypes = params.EventTypes
+	}
+	eventTypes, ok = validateWebhookParams(w, url, eventTypes)
+	if !ok {
+		return
+	}
+
+	err = api.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		endpoint, err = q.UpdateWebhookEndpoint(r.Context(), database.UpdateWebhookEndpointParams{
+			Url:        url,
+			EventTypes: eventTypes,
+			ID:         endpoint.ID,
+			UserID:     userID,
+		})
+		if err != nil || params.Enabled == nil {
+			return err
+		}
+		if *params.Enabled {
+			err = q.EnableWebhookEndpoint(r.Context(), database.EnableWebhookEndpointParams{
+				ID:     endpoint.ID,
+				UserID: userID,
+			})
+		} else {
+			err = q.DisableWebhookEndpoint(r.Context(), database.DisableWebhookEndpointParams{
+				Reason: "Disabled by owner",
+				ID:     endpoint.ID,
+				UserID: userID,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		endpoint, err = q.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+			ID:     endpoint.ID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update webhook")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEndpointFromDB(endpoint))
+}
+
+func (api *apiConfig) handlerDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, endpoint, ok := api.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+	_, err := api.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpoint.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetWebhookDeliveries pages backwards through an endpoint's
+// deliveries, each with the log of its attempts.
+func (api *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := api.webhookEndpointForRequest(w, r)
+	if !ok {
+		return
+	}
+	limit, before, beforeID, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	deliveries, err := api.db.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Before:     before,
+		BeforeID:   beforeID,
+		PageSize:   limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get deliveries")
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(deliveries))
+	for _, d := range deliveries {
+		ids = append(ids, d.ID)
+	}
+	attempts, err := api.db.GetWebhookDeliveryAttempts(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get delivery log")
+		return
+	}
+	byDelivery := map[uuid.UUID][]WebhookAttempt{}
+	for _, a := range attempts {
+		byDelivery[a.DeliveryID] = append(byDelivery[a.DeliveryID], WebhookAttempt{
+			AttemptedAt:  a.AttemptedAt,
+			ResponseCode: nullInt32Ptr(a.ResponseCode),
+			Error:        a.Error,
+			DurationMs:   a.DurationMs,
+		})
+	}
+
+	responseDeliveries := []WebhookDelivery{}
+	for _, d := range deliveries {
+		delivery := WebhookDelivery{
+			ID:           d.ID,
+			CreatedAt:    d.CreatedAt,
+			EventType:    d.EventType,
+			Payload:      json.RawMessage(d.Payload),
+			Status:       d.Status,
+			Attempts:     d.Attempts,
+			ResponseCode: nullInt32Ptr(d.ResponseCode),
+			DeliveredAt:  nullTimePtr(d.DeliveredAt),
+			Log:          byDelivery[d.ID],
+		}
+		if d.Status == "pending" {
+			delivery.NextAttemptAt = &d.NextAttemptAt
+		}
+		if delivery.Log == nil {
+			delivery.Log = []WebhookAttempt{}
+		}
+		responseDeliveries = append(responseDeliveries, delivery)
+	}
+	respondWithJSON(w, http.StatusOK, responseDeliveries)
+}
+
+// webhookEndpointForRequest authenticates the caller and loads the
+// {webhookID} endpoint, which must belong to them.
+func (api *apiConfig) webhookEndpointForRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.WebhookEndpoint, bool) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, database.WebhookEndpoint{}, false
+	}
+	endpointID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return uuid.Nil, database.WebhookEndpoint{}, false
+	}
+	endpoint, err := api.db.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook")
+		return uuid.Nil, database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get webhook")
+		return uuid.Nil, database.WebhookEndpoint{}, false
+	}
+	return userID, endpoint, true
+}
+
+// validateWebhookParams checks an endpoint's URL and event types, and
+// returns the event types without duplicates.
+func validateWebhookParams(w http.ResponseWriter, url string, eventTypes []string) ([]string, bool) {
+	err := webhooks.ValidateURL(url)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if len(eventTypes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Subscribe to at least one event type")
+		return nil, false
+	}
+	var distinct []string
+	for _, t := range eventTypes {
+		if !webhooks.ValidEventType(t) {
+			respondWithError(w, http.StatusBadRequest, "Unknown event type: "+t)
+			return nil, false
+		}
+		if !slices.Contains(distinct, t) {
+			distinct = append(distinct, t)
+		}
+	}
+	return distinct, true
+}
+
+func webhookEndpointFromDB(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:                  endpoint.ID,
+		CreatedAt:           endpoint.CreatedAt,
+		UpdatedAt:           endpoint.UpdatedAt,
+		URL:                 endpoint.Url,
+		EventTypes:          endpoint.EventTypes,
+		Global:              endpoint.Global,
+		Enabled:             !endpoint.DisabledAt.Valid,
+		DisabledAt:          nullTimePtr(endpoint.DisabledAt),
+		DisabledReason:      endpoint.DisabledReason,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+	}
+}
+
+func nullInt32Ptr(n sql.NullInt32) *int32 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int32
+}
+
+// enqueueWebhookEvent queues an event about userID for every endpoint
+// subscribed to it, in q's transaction. Chirp events are queued by the
+// database itself.
+func enqueueWebhookEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return q.EnqueueWebhookEvent(ctx, database.EnqueueWebhookEventParams{
+		UserID:    userID,
+		EventType: eventType,
+		Data:      payload,
+	})
+}
+
+// deliverWebhooks attempts every delivery that is due. Batches are claimed
+// with FOR UPDATE SKIP LOCKED, so instances never attempt the same
+// delivery at once, and delivered concurrently, so one slow endpoint
+// doesn't hold up the rest.
+//
+// Deliveries are queued in webhook_deliveries rather than as a job each.
+// They're created in SQL, by the chirp event trigger and
+// enqueue_webhook_event, one for every subscribed endpoint, in the
+// transaction that made the change; jobs.Enqueue can't be called from
+// there. The table is also the delivery log endpoint owners page through,
+// and a disabled endpoint's deliveries wait, unclaimed, until it's enabled
+// again, instead of using up attempts. So deliverWebhooks itself runs as
+// a periodic job, and it claims deliveries the way the Runner claims jobs,
+// with next_attempt_at standing in for the lease.
+func (api *apiConfig) deliverWebhooks(ctx context.Context) {
+	for {
+		deliveries, err := api.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeaseUntil: time.Now().UTC().Add(webhookDeliveryLease),
+			BatchSize:  webhookBatchSize,
+		})
+		if err != nil {
+			log.Printf("Error claiming webhook deliveries: %s", err)
+			return
+		}
+		var wg sync.WaitGroup
+		for _, d := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				api.deliverWebhook(ctx, d)
+			}()
+		}
+		wg.Wait()
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (api *apiConfig) deliverWebhook(ctx context.Context, d database.ClaimWebhookDeliveriesRow) {
+	attemptedAt := time.Now().UTC()
+	result := api.webhooks.Send(ctx, webhooks.Request{
+		URL:        d.Url,
+		Secret:     d.Secret,
+		DeliveryID: d.ID,
+		EventType:  d.EventType,
+		Payload:    []byte(d.Payload),
+	})
+	responseCode := sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0}
+	errMsg := ""
+	if result.Err != nil {
+		errMsg = result.Err.Error()
+	}
+	err := api.db.RecordWebhookAttempt(ctx, database.RecordWebhookAttemptParams{
+		DeliveryID:   d.ID,
+		AttemptedAt:  attemptedAt,
+		ResponseCode: responseCode,
+		Error:        errMsg,
+		DurationMs:   int32(result.Duration.Milliseconds()),
+	})
+	if err != nil {
+		log.Printf("Error recording webhook attempt for %s: %s", d.ID, err)
+	}
+
+	if result.Err == nil {
+		err = api.db.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+			ResponseCode: responseCode,
+			ID:           d.ID,
+		})
+		if err == nil {
+			err = api.db.ResetWebhookEndpointFailures(ctx, d.EndpointID)
+		}
+		if err != nil {
+			log.Printf("Error recording webhook delivery %s: %s", d.ID, err)
+		}
+		return
+	}
+
+	var retryAt sql.NullTime
+	if d.Attempts < webhookMaxAttempts {
+		retryAt = sql.NullTime{Time: time.Now().UTC().Add(webhooks.Backoff(int(d.Attempts))), Valid: true}
+	}
+	err = api.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		RetryAt:      retryAt,
+		ResponseCode: responseCode,
+		ID:           d.ID,
+	})
+	if err != nil {
+		log.Printf("Error recording failed webhook delivery %s: %s", d.ID, err)
+		return
+	}
+	endpoint, err := api.db.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+		DisableAfterFailures: webhookDisableAfterFailures,
+		FailingBefore:        time.Now().UTC().Add(-webhookDisableAfter),
+		Reason:               "Too many failed deliveries",
+		ID:                   d.EndpointID,
+	})
+	if err != nil {
+		log.Printf("Error recording webhook endpoint failure for %s: %s", d.EndpointID, err)
+		return
+	}
+	if endpoint.DisabledAt.Valid {
+		log.Printf("Disabled webhook endpoint %s after %d failed deliveries", endpoint.ID, endpoint.ConsecutiveFailures)
+	}
+}
+
+// purgeWebhookDeliveries removes finished deliveries, and their logs, once
+// they're old.
+func (api *apiConfig) purgeWebhookDeliveries(ctx context.Context) {
+	_, err := api.db.PurgeWebhookDeliveries(ctx, time.Now().Add(-webhookDeliveryRetention))
+	if err != nil {
+		log.Printf("Error purging webhook deliveries: %s", err)
+	}
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/auth"
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/Madlite/chirpy/internal/stream"
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	wsAuthTimeout  = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsWriteWait    = 10 * time.Second
+	wsReadLimit    = 4096
+	wsSendBuffer   = 64
+	wsMaxThreads   = 50
+)
+
+// wsCloseTokenExpired closes connections whose access token ran out
+// without the client re-authenticating.
+const wsCloseTokenExpired websocket.StatusCode = 4001
+
+// WebSocket channels a client can subscribe to.
+const (
+	wsChannelTimeline = "timeline"
+	wsChannelMentions = "mentions"
+	wsChannelThread   = "thread"
+)
+
+// wsClientMessage is a message from the client.
+type wsClientMessage struct {
+	Type    string    `json:"type"`
+	Token   string    `json:"token"`
+	Channel string    `json:"channel"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// wsServerMessage is a message to the client.
+type wsServerMessage struct {
+	Type      string     `json:"type"`
+	Channel   string     `json:"channel,omitempty"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	Event     string     `json:"event,omitempty"`
+	Data      any        `json:"data,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// errSlowConsumer ends connections that can't keep up with their events.
+var errSlowConsumer = &websocket.CloseError{Code: websocket.StatusTryAgainLater, Reason: "too slow"}
+
+// errWSSuspended ends the connections of suspended users.
+var errWSSuspended = &websocket.CloseError{Code: websocket.StatusPolicyViolation, Reason: "account suspended"}
+
+// errSessionSuspended is returned by authenticated for suspended users.
+var errSessionSuspended = errors.New("account is suspended")
+
+// handlerWebSocket serves live timelines and mentions over a WebSocket.
+//
+// The client authenticates with the same access token as the rest of the
+// API, either in the Authorization header of the handshake or with a
+// {"type": "auth", "token": ...} message within wsAuthTimeout. Sending
+// another auth message before the token expires extends the connection;
+// otherwise it is closed with wsCloseTokenExpired.
+//
+// Once authenticated, the client sends {"type": "subscribe", "channel": ...}
+// and "unsubscribe" for these channels:
+//
+//   - timeline: the caller's chirps and those of the accounts they follow
+//   - mentions: new chirps mentioning the caller's username
+//   - thread: one chirp, given as chirp_id
+//
+// Events arrive as {"type": "event", "channel", "event", "data"}, with the
+// same payloads as GET /api/stream/chirps. The server pings every
+// wsPingInterval and drops clients that stop answering or fall more than
+// wsSendBuffer messages behind. Suspended users are refused when they
+// authenticate, and signed-in connections are checked every
+// wsPingInterval and closed if the user has since been suspended.
+func (api *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	s := &wsSession{
+		api:     api,
+		send:    make(chan wsServerMessage, wsSendBuffer),
+		threads: map[uuid.UUID]bool{},
+	}
+	if r.Header.Get("Authorization") != "" {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Error validating token")
+			return
+		}
+		s.userID, s.expiresAt, err = auth.ValidateJWTWithExpiry(token, api.jwtSecret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Error validating token")
+			return
+		}
+	}
+
+	// Any origin may connect. Clients authenticate with a bearer token
+	// rather than cookies, so a page on another site can't act as a
+	// signed-in user.
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		// Accept has already replied.
+		return
+	}
+	s.conn = conn
+	s.run(r.Context())
+}
+
+// wsSession is one WebSocket connection. Its fields are only touched by the
+// goroutine running run; reading, writing and pinging happen on their own
+// goroutines and talk to it over channels.
+type wsSession struct {
+	api  *apiConfig
+	conn *websocket.Conn
+	send chan wsServerMessage
+
+	userID    uuid.UUID
+	username  string
+	expiresAt time.Time
+	sub       *stream.Subscription
+
+	timeline bool
+	mentions bool
+	threads  map[uuid.UUID]bool
+}
+
+func (s *wsSession) run(ctx context.Context) {
+	defer s.conn.CloseNow()
+	defer func() {
+		if s.sub != nil {
+			s.sub.Close()
+		}
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.conn.SetReadLimit(wsReadLimit)
+	quit := make(chan struct{})
+	incoming := make(chan []byte)
+	go s.readLoop(ctx, incoming, quit)
+	go s.pingLoop(ctx, quit)
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeLoop(ctx, quit)
+	}()
+
+	closeErr := s.serve(ctx, incoming)
+
+	// Stop the writer before starting the closing handshake, so nothing
+	// is sent after the close frame.
+	close(quit)
+	<-writerDone
+	if closeErr != nil {
+		s.conn.Close(closeErr.Code, closeErr.Reason)
+	}
+}
+
+// serve handles messages and events until the connection should end. It
+// returns how to close the connection, or nil if it is already gone.
+func (s *wsSession) serve(ctx context.Context, incoming <-chan []byte) *websocket.CloseError {
+	// Until the client authenticates this is the auth timeout, and after
+	// that the token's expiry.
+	deadline := time.NewTimer(wsAuthTimeout)
+	defer deadline.Stop()
+	if s.userID != uuid.Nil {
+		err := s.authenticated(ctx, deadline)
+		if errors.Is(err, errSessionSuspended) {
+			return errWSSuspended
+		}
+		if err != nil {
+			log.Printf("Error starting WebSocket session: %s", err)
+			return &websocket.CloseError{Code: websocket.StatusTryAgainLater, Reason: "try again later"}
+		}
+	}
+
+	suspensionCheck := time.NewTicker(wsPingInterval)
+	defer suspensionCheck.Stop()
+	for {
+		var events <-chan stream.Event
+		if s.sub != nil {
+			events = s.sub.Events()
+		}
+		select {
+		case data, ok := <-incoming:
+			if !ok {
+				return nil
+			}
+			closeErr := s.handleMessage(ctx, deadline, data)
+			if closeErr != nil {
+				return closeErr
+			}
+		case e, ok := <-events:
+			if !ok {
+				// Dropped by the hub for falling behind.
+				return errSlowConsumer
+			}
+			closeErr := s.handleEvent(ctx, e)
+			if closeErr != nil {
+				return closeErr
+			}
+		case <-suspensionCheck.C:
+			if s.userID == uuid.Nil {
+				continue
+			}
+			isSuspended, err := s.api.isSuspended(ctx, s.userID)
+			if err != nil {
+				log.Printf("Error checking suspension of %s: %s", s.userID, err)
+				continue
+			}
+			if isSuspended {
+				return errWSSuspended
+			}
+		case <-deadline.C:
+			if s.userID == uuid.Nil {
+				return &websocket.CloseError{Code: websocket.StatusPolicyViolation, Reason: "authentication timed out"}
+			}
+			return &websocket.CloseError{Code: wsCloseTokenExpired, Reason: "token expired"}
+		}
+	}
+}
+
+// authenticated finishes signing the client in once s.userID and
+// s.expiresAt are set, and tells the client when to re-authenticate. It
+// returns errSessionSuspended if the user is suspended.
+func (s *wsSession) authenticated(ctx context.Context, deadline *time.Timer) error {
+	user, err := s.api.db.GetUserByID(ctx, s.userID)
+	if err != nil {
+		return err
+	}
+	if suspended(user.SuspendedAt, user.SuspendedUntil) {
+		return errSessionSuspended
+	}
+	s.username = user.Username.String
+	if s.sub == nil {
+		s.sub = s.api.chirpEvents.Subscribe(wsSendBuffer)
+	}
+	deadline.Reset(time.Until(s.expiresAt))
+	expiresAt := s.expiresAt
+	s.queue(wsServerMessage{Type: "authenticated", ExpiresAt: &expiresAt})
+	return nil
+}
+
+func (s *wsSession) handleMessage(ctx context.Context, deadline *time.Timer, data []byte) *websocket.CloseError {
+	var msg wsClientMessage
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return s.queueError("Couldn't decode message")
+	}
+	if msg.Type == "ping" {
+		if !s.queue(wsServerMessage{Type: "pong"}) {
+			return errSlowConsumer
+		}
+		return nil
+	}
+	if msg.Type == "auth" {
+		userID, expiresAt, err := auth.ValidateJWTWithExpiry(msg.Token, s.api.jwtSecret)
+		if err != nil {
+			return s.queueError("Error validating token")
+		}
+		if s.userID != uuid.Nil && userID != s.userID {
+			return s.queueError("Token is for a different user")
+		}
+		s.userID = userID
+		s.expiresAt = expiresAt
+		err = s.authenticated(ctx, deadline)
+		if errors.Is(err, errSessionSuspended) {
+			return errWSSuspended
+		}
+		if err != nil {
+			log.Printf("Error authenticating WebSocket session: %s", err)
+			return s.queueError("Couldn't authenticate")
+		}
+		return nil
+	}
+	if s.userID == uuid.Nil {
+		return s.queueError("Not authenticated")
+	}
+
+	switch msg.Type {
+	case "subscribe":
+		return s.subscribe(ctx, msg)
+	case "unsubscribe":
+		switch msg.Channel {
+		case wsChannelTimeline:
+			s.timeline = false
+		case wsChannelMentions:
+			s.mentions = false
+		case wsChannelThread:
+			delete(s.threads, msg.ChirpID)
+		default:
+			return s.queueError("Unknown channel")
+		}
+		return s.queueReply("unsubscribed", msg)
+	default:
+		return s.queueError("Unknown message type")
+	}
+}
+
+func (s *wsSession) subscribe(ctx context.Context, msg wsClientMessage) *websocket.CloseError {
+	switch msg.Channel {
+	case wsChannelTimeline:
+		s.timeline = true
+	case wsChannelMentions:
+		if s.username == "" {
+			return s.queueError("Set a username to be mentioned")
+		}
+		s.mentions = true
+	case wsChannelThread:
+		if s.threads[msg.ChirpID] {
+			break
+		}
+		if len(s.threads) >= wsMaxThreads {
+			return s.queueError("Subscribed to too many threads")
+		}
+		_, err := s.api.db.GetVisibleChirp(ctx, database.GetVisibleChirpParams{
+			ID:       msg.ChirpID,
+			ViewerID: s.userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.queueError("Chirp not found")
+		}
+		if err != nil {
+			return s.queueError("Couldn't get chirp")
+		}
+		s.threads[msg.ChirpID] = true
+	default:
+		return s.queueError("Unknown channel")
+	}
+	return s.queueReply("subscribed", msg)
+}
+
+// handleEvent sends e on every channel it belongs to.
+func (s *wsSession) handleEvent(ctx context.Context, e stream.Event) *websocket.CloseError {
+	var channels []string
+	if s.timeline && s.inTimeline(ctx, e.UserID) {
+		channels = append(channels, wsChannelTimeline)
+	}
+	if s.threads[e.ChirpID] {
+		channels = append(channels, wsChannelThread)
+	}
+	wantMention := s.mentions && e.Kind == stream.KindCreated && e.UserID != s.userID
+	if len(channels) == 0 && !wantMention {
+		return nil
+	}
+
+	payload, ok, err := s.api.chirpEventPayload(ctx, s.userID, e)
+	if err != nil {
+		log.Printf("Error preparing chirp event %d: %s", e.ID, err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	if chirp, isChirp := payload.(Chirp); wantMention && isChirp &&
+		slices.Contains(mentionedUsernames(chirp.Body), strings.ToLower(s.username)) {
+		channels = append(channels, wsChannelMentions)
+	}
+	for _, channel := range channels {
+		msg := wsServerMessage{
+			Type:    "event",
+			Channel: channel,
+			Event:   "chirp." + e.Kind,
+			Data:    payload,
+		}
+		if channel == wsChannelThread {
+			chirpID := e.ChirpID
+			msg.ChirpID = &chirpID
+		}
+		if !s.queue(msg) {
+			return errSlowConsumer
+		}
+	}
+	return nil
+}
+
+// inTimeline reports whether authorID's chirps belong in the caller's
+// timeline.
+func (s *wsSession) inTimeline(ctx context.Context, authorID uuid.UUID) bool {
+	if authorID == s.userID {
+		return true
+	}
+	follow, err := s.api.db.GetFollow(ctx, database.GetFollowParams{
+		FollowerID: s.userID,
+		FolloweeID: authorID,
+	})
+	return err == nil && follow.Status == "accepted"
+}
+
+// queue hands msg to the writer, and reports false if the client is too
+// far behind to take it.
+func (s *wsSession) queue(msg wsServerMessage) bool {
+	select {
+	case s.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *wsSession) queueReply(msgType string, msg wsClientMessage) *websocket.CloseError {
+	reply := wsServerMessage{Type: msgType, Channel: msg.Channel}
+	if msg.Channel == wsChannelThread {
+		chirpID := msg.ChirpID
+		reply.ChirpID = &chirpID
+	}
+	if !s.queue(reply) {
+		return errSlowConsumer
+	}
+	return nil
+}
+
+func (s *wsSession) queueError(msg string) *websocket.CloseError {
+	if !s.queue(wsServerMessage{Type: "error", Error: msg}) {
+		return errSlowConsumer
+	}
+	return nil
+}
+
+// readLoop passes messages from the client to incoming, and closes it when
+// the connection fails or the client closes it.
+func (s *wsSession) readLoop(ctx context.Context, incoming chan<- []byte, quit <-chan struct{}) {
+	defer close(incoming)
+	for {
+		_, data, err := s.conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		select {
+		case incoming <- data:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// writeLoop sends queued messages until quit is closed. If a write fails
+// it closes the connection, which ends the read loop too.
+func (s *wsSession) writeLoop(ctx context.Context, quit <-chan struct{}) {
+	for {
+		select {
+		case <-quit:
+			return
+		case msg := <-s.send:
+			data, err := json.Marshal(msg)
+			if err != nil {
+				log.Printf("Error encoding WebSocket message: %s", err)
+				continue
+			}
+			writeCtx, cancel := context.WithTimeout(ctx, wsWriteWait)
+			err = s.conn.Write(writeCtx, websocket.MessageText, data)
+			cancel()
+			if err != nil {
+				s.conn.CloseNow()
+				return
+			}
+		}
+	}
+}
+
+// pingLoop pings the client every wsPingInterval until quit is closed, and
+// drops the connection if a pong doesn't come back within wsPongWait.
+func (s *wsSession) pingLoop(ctx context.Context, quit <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, wsPongWait)
+		err := s.conn.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			s.conn.CloseNow()
+			return
+		}
+	}
+}
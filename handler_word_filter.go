@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Madlite/chirpy/internal/filter"
+)
+
+// defaultFilterRules are used until an admin saves a word list of their
+// own.
+var defaultFilterRules = []filter.Rule{
+	{Word: "kerfuffle", Action: filter.ActionMask},
+	{Word: "sharbert", Action: filter.ActionMask},
+	{Word: "fornax", Action: filter.ActionMask},
+}
+
+var errBannedWord = errors.New("Chirp contains a banned word")
+
+// moderateText runs user-written text through the word filter. It returns
+// the text with masked words replaced and whether it should be flagged for
+// moderation, or errBannedWord if it mustn't be posted at all.
+func (api *apiConfig) moderateText(text string) (string, bool, error) {
+	result := api.filter.Check(text)
+	if result.Rejected {
+		return "", false, errBannedWord
+	}
+	return result.Text, result.Flagged, nil
+}
+
+func (api *apiConfig) handlerGetFilterRules(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, api.filter.Rules())
+}
+
+func (api *apiConfig) handlerSetFilterRule(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		Action filter.Action `json:"action"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+	rule := filter.Rule{
+		Word:   r.PathValue("word"),
+		Action: params.Action,
+	}
+	err = api.filter.SetRule(rule)
+	if err != nil {
+		if errors.Is(err, filter.ErrInvalidRule) {
+			respondWithError(w, http.StatusBadRequest, "Rules need a word and an action of mask, reject or flag")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save filter rules")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, rule)
+}
+
+func (api *apiConfig) handlerDeleteFilterRule(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	removed, err := api.filter.RemoveRule(r.PathValue("word"))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save filter rules")
+		return
+	}
+	if !removed {
+		respondWithError(w, http.StatusNotFound, "Couldn't find rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerReloadFilter re-reads the word list from its config file, for
+// when it has been edited by hand.
+func (api *apiConfig) handlerReloadFilter(w http.ResponseWriter, r *http.Request) {
+	_, ok := api.authenticateAdmin(w, r)
+	if !ok {
+		return
+	}
+	err := api.filter.Reload()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reload filter rules: "+err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, api.filter.Rules())
+}
@@ -0,0 +1,221 @@
+// Package audit keeps an append-only, tamper-evident log of security and
+// admin events.
+//
+// Every event's hash covers its own contents and the hash of the event
+// before it, so editing, reordering or deleting an event breaks the chain
+// from that point on. Appends take a transaction-scoped advisory lock so
+// concurrent writers can't fork the chain.
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the log.
+const (
+	ActionLoginSucceeded  = "login.succeeded"
+	ActionLoginFailed     = "login.failed"
+	ActionPasswordChanged = "user.password_changed"
+	ActionEmailChanged    = "user.email_changed"
+	ActionTokenRevoked    = "token.revoked"
+	ActionSessionsRevoked = "token.revoked_all"
+	ActionUserUpgraded    = "webhook.user_upgraded"
+	ActionAdminReset      = "admin.reset"
+	ActionJobRetried      = "admin.job_retried"
+)
+
+// lockKey is the pg_advisory_xact_lock key serialising appends.
+const lockKey = 0x61756469745f6c6f // "audit_lo"
+
+const verifyPageSize = 1000
+
+// Event describes something to record. ActorID is uuid.Nil when the event
+// wasn't caused by a signed-in user, such as a failed login or a webhook.
+type Event struct {
+	ActorID    uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	Metadata   map[string]string
+}
+
+// Record appends e to the log in a transaction of its own.
+func Record(ctx context.Context, db *sql.DB, e Event) (database.AuditEvent, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.AuditEvent{}, err
+	}
+	defer tx.Rollback()
+
+	recorded, err := Append(ctx, database.New(tx), e)
+	if err != nil {
+		return database.AuditEvent{}, err
+	}
+	return recorded, tx.Commit()
+}
+
+// Append adds e to the log through q, which must be bound to a
+// transaction: the lock keeping the chain linear is held until that
+// transaction ends, and the event is only kept if it commits.
+func Append(ctx context.Context, q *database.Queries, e Event) (database.AuditEvent, error) {
+	err := q.LockAuditLog(ctx, lockKey)
+	if err != nil {
+		return database.AuditEvent{}, err
+	}
+	ev := database.AuditEvent{
+		ID:         1,
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		ActorID:    uuid.NullUUID{UUID: e.ActorID, Valid: e.ActorID != uuid.Nil},
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Ip:         e.IP,
+		Metadata:   "{}",
+	}
+	latest, err := q.GetLatestAuditEvent(ctx)
+	if err == nil {
+		ev.ID = latest.ID + 1
+		ev.PrevHash = latest.Hash
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return database.AuditEvent{}, err
+	}
+	if len(e.Metadata) > 0 {
+		// Maps marshal with sorted keys, so the stored text is stable.
+		data, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return database.AuditEvent{}, err
+		}
+		ev.Metadata = string(data)
+	}
+	ev.Hash = Hash(ev)
+
+	return q.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		ID:         ev.ID,
+		CreatedAt:  ev.CreatedAt,
+		ActorID:    ev.ActorID,
+		Action:     ev.Action,
+		TargetType: ev.TargetType,
+		TargetID:   ev.TargetID,
+		Ip:         ev.Ip,
+		Metadata:   ev.Metadata,
+		PrevHash:   ev.PrevHash,
+		Hash:       ev.Hash,
+	})
+}
+
+// Hash returns the hex SHA-256 of ev's contents and ev.PrevHash. Each field
+// is length-prefixed so no two different events share an encoding. The
+// stored Hash field itself is not included.
+func Hash(ev database.AuditEvent) string {
+	actor := ""
+	if ev.ActorID.Valid {
+		actor = ev.ActorID.UUID.String()
+	}
+	h := sha256.New()
+	for _, field := range []string{
+		strconv.FormatInt(ev.ID, 10),
+		ev.CreatedAt.UTC().Format(time.RFC3339Nano),
+		actor,
+		ev.Action,
+		ev.TargetType,
+		ev.TargetID,
+		ev.Ip,
+		ev.Metadata,
+		ev.PrevHash,
+	} {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashEmail returns a keyed hash of email, for events about an address
+// that may not belong to anyone. The log is append-only, so it mustn't keep
+// the address itself, but repeated attempts on the same address still
+// share a hash. Case and surrounding space are ignored. Changing key
+// changes every hash.
+func HashEmail(key, email string) string {
+	h := hmac.New(sha256.New, []byte("audit email:"+key))
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ChainError reports the first event at which the chain fails to verify.
+type ChainError struct {
+	ID     int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit event %d: %s", e.ID, e.Reason)
+}
+
+// Result summarises a verified chain. Head is the hash of the newest
+// event; noting it down and checking it later also catches events removed
+// from the end of the log, which the chain alone can't.
+type Result struct {
+	Events int64
+	Head   string
+}
+
+// Verify walks the whole log in order and checks every link. It returns a
+// *ChainError if the log has been tampered with.
+func Verify(ctx context.Context, q *database.Queries) (Result, error) {
+	var c chain
+	for {
+		events, err := q.GetAuditEventsAfter(ctx, database.GetAuditEventsAfterParams{
+			AfterID:  c.lastID,
+			PageSize: verifyPageSize,
+		})
+		if err != nil {
+			return c.result(), err
+		}
+		for _, ev := range events {
+			err = c.check(ev)
+			if err != nil {
+				return c.result(), err
+			}
+		}
+		if len(events) < verifyPageSize {
+			return c.result(), nil
+		}
+	}
+}
+
+// chain tracks the verified prefix of the log.
+type chain struct {
+	lastID int64
+	head   string
+}
+
+func (c *chain) check(ev database.AuditEvent) error {
+	if ev.ID != c.lastID+1 {
+		return &ChainError{ID: ev.ID, Reason: fmt.Sprintf("expected event %d to come next", c.lastID+1)}
+	}
+	if ev.PrevHash != c.head {
+		return &ChainError{ID: ev.ID, Reason: "previous hash doesn't match the preceding event"}
+	}
+	if Hash(ev) != ev.Hash {
+		return &ChainError{ID: ev.ID, Reason: "hash doesn't match the event's contents"}
+	}
+	c.lastID = ev.ID
+	c.head = ev.Hash
+	return nil
+}
+
+func (c *chain) result() Result {
+	return Result{Events: c.lastID, Head: c.head}
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// testChain builds a correctly linked chain of n events.
+func testChain(n int) []database.AuditEvent {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := make([]database.AuditEvent, 0, n)
+	prev := ""
+	for i := range n {
+		ev := database.AuditEvent{
+			ID:         int64(i + 1),
+			CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+			ActorID:    uuid.NullUUID{UUID: uuid.New(), Valid: true},
+			Action:     ActionLoginSucceeded,
+			TargetType: "user",
+			TargetID:   uuid.NewString(),
+			Ip:         "203.0.113.7",
+			Metadata:   "{}",
+			PrevHash:   prev,
+		}
+		ev.Hash = Hash(ev)
+		prev = ev.Hash
+		events = append(events, ev)
+	}
+	return events
+}
+
+func checkAll(events []database.AuditEvent) error {
+	var c chain
+	for _, ev := range events {
+		err := c.check(ev)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHashIsStable(t *testing.T) {
+	ev := testChain(1)[0]
+	if got := Hash(ev); got != ev.Hash {
+		t.Fatalf("Hash changed between calls: %s != %s", got, ev.Hash)
+	}
+	// The stored hash isn't part of the input.
+	ev.Hash = "something else"
+	if Hash(ev) == ev.Hash {
+		t.Fatal("Hash should ignore the stored hash")
+	}
+
+	// A time read back in another location hashes the same.
+	other := ev
+	other.CreatedAt = ev.CreatedAt.In(time.FixedZone("UTC+2", 2*60*60))
+	if Hash(other) != Hash(ev) {
+		t.Error("Hash depends on the time's location")
+	}
+}
+
+func TestHashCoversEveryField(t *testing.T) {
+	base := testChain(1)[0]
+	edits := map[string]func(*database.AuditEvent){
+		"id":          func(ev *database.AuditEvent) { ev.ID++ },
+		"created_at":  func(ev *database.AuditEvent) { ev.CreatedAt = ev.CreatedAt.Add(time.Microsecond) },
+		"actor_id":    func(ev *database.AuditEvent) { ev.ActorID = uuid.NullUUID{} },
+		"action":      func(ev *database.AuditEvent) { ev.Action = ActionLoginFailed },
+		"target_type": func(ev *database.AuditEvent) { ev.TargetType = "token" },
+		"target_id":   func(ev *database.AuditEvent) { ev.TargetID = uuid.NewString() },
+		"ip":          func(ev *database.AuditEvent) { ev.Ip = "198.51.100.1" },
+		"metadata":    func(ev *database.AuditEvent) { ev.Metadata = `{"reason":"bad_password"}` },
+		"prev_hash":   func(ev *database.AuditEvent) { ev.PrevHash = "00" },
+	}
+	for field, edit := range edits {
+		ev := base
+		edit(&ev)
+		if Hash(ev) == base.Hash {
+			t.Errorf("changing %s didn't change the hash", field)
+		}
+	}
+
+	// Moving bytes between adjacent fields must not collide.
+	a, b := base, base
+	a.TargetType, a.TargetID = "user", "x"
+	b.TargetType, b.TargetID = "use", "rx"
+	if Hash(a) == Hash(b) {
+		t.Error("field boundaries aren't part of the hash")
+	}
+}
+
+func TestChainVerifies(t *testing.T) {
+	err := checkAll(testChain(5))
+	if err != nil {
+		t.Fatalf("untouched chain failed to verify: %s", err)
+	}
+}
+
+func TestChainDetectsTampering(t *testing.T) {
+	tests := map[string]struct {
+		tamper func([]database.AuditEvent) []database.AuditEvent
+		wantID int64
+	}{
+		"edited event": {
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events[2].Ip = "192.0.2.1"
+				return events
+			},
+			wantID: 3,
+		},
+		"edited and rehashed event": {
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events[2].Action = ActionAdminReset
+				events[2].Hash = Hash(events[2])
+				return events
+			},
+			wantID: 4,
+		},
+		"deleted event": {
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			wantID: 3,
+		},
+		"deleted and renumbered": {
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events = append(events[:1], events[2:]...)
+				for i := range events {
+					events[i].ID = int64(i + 1)
+				}
+				return events
+			},
+			wantID: 2,
+		},
+	}
+	for name, tt := range tests {
+		err := checkAll(tt.tamper(testChain(5)))
+		var chainErr *ChainError
+		if !errors.As(err, &chainErr) {
+			t.Errorf("%s: got %v, want a ChainError", name, err)
+			continue
+		}
+		if chainErr.ID != tt.wantID {
+			t.Errorf("%s: chain broke at event %d, want %d", name, chainErr.ID, tt.wantID)
+		}
+	}
+}
+
+func TestHashEmail(t *testing.T) {
+	h := HashEmail("key", "Someone@Example.com ")
+	if h != HashEmail("key", "someone@example.com") {
+		t.Error("HashEmail should ignore case and surrounding space")
+	}
+	if h == HashEmail("other key", "someone@example.com") {
+		t.Error("HashEmail should depend on the key")
+	}
+	if h == HashEmail("key", "someone.else@example.com") {
+		t.Error("different emails share a hash")
+	}
+}
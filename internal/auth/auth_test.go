@@ -3,6 +3,7 @@ package auth
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -67,3 +68,25 @@ func TestGetBearerToken(t *testing.T) {
 		t.Fatalf("Expected token not equal err: %v", err)
 	}
 }
+
+func TestValidateJWTWithExpiry(t *testing.T) {
+	secret := "test-secret"
+	userID := uuid.New()
+
+	before := time.Now()
+	token, err := MakeJWT(userID, secret)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	gotID, expiresAt, err := ValidateJWTWithExpiry(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateJWTWithExpiry failed: %v", err)
+	}
+	if gotID != userID {
+		t.Fatalf("expected userID %s, got %s", userID, gotID)
+	}
+	if expiresAt.Before(before.Add(time.Hour-time.Second)) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("expected expiry about an hour from now, got %s", expiresAt)
+	}
+}
@@ -0,0 +1,54 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDiskStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskStore failed: %v", err)
+	}
+
+	err = store.Put(ctx, "avatars/a.png", strings.NewReader("image data"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	rc, err := store.Open(ctx, "avatars/a.png")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("reading blob failed: %v", err)
+	}
+	if string(data) != "image data" {
+		t.Fatalf("expected %q, got %q", "image data", data)
+	}
+
+	err = store.Delete(ctx, "avatars/a.png")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	_, err = store.Open(ctx, "avatars/a.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestDiskStoreRejectsTraversal(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskStore failed: %v", err)
+	}
+	err = store.Put(context.Background(), "../escape", strings.NewReader("x"))
+	if err == nil {
+		t.Fatal("expected key outside the store directory to be rejected")
+	}
+}
@@ -7,29 +7,71 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const clearForcedChirpSensitive = `-- name: ClearForcedChirpSensitive :one
+UPDATE chirps
+SET sensitive = FALSE,
+    sensitive_forced_by = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, content_warning, sensitive, sensitive_forced_by, flagged_at, hidden_at, hidden_by, deleted_at
+`
+
+func (q *Queries) ClearForcedChirpSensitive(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, clearForcedChirpSensitive, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, content_warning, sensitive, flagged_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    CASE WHEN $5::bool THEN NOW() END
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, content_warning, sensitive, sensitive_forced_by, flagged_at, hidden_at, hidden_by, deleted_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body           string
+	UserID         uuid.UUID
+	ContentWarning string
+	Sensitive      bool
+	Flagged        bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ContentWarning,
+		arg.Sensitive,
+		arg.Flagged,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,13 +79,21 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE user_id = $1 
+WHERE user_id = $1
   AND id = $2
 `
 
@@ -57,10 +107,45 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error
 	return err
 }
 
+const forceChirpSensitive = `-- name: ForceChirpSensitive :one
+UPDATE chirps
+SET sensitive = TRUE,
+    sensitive_forced_by = $1
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, content_warning, sensitive, sensitive_forced_by, flagged_at, hidden_at, hidden_by, deleted_at
+`
+
+type ForceChirpSensitiveParams struct {
+	ModeratorID uuid.NullUUID
+	ID          uuid.UUID
+}
+
+func (q *Queries) ForceChirpSensitive(ctx context.Context, arg ForceChirpSensitiveParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, forceChirpSensitive, arg.ModeratorID, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, pinned_at, content_warning, sensitive, sensitive_forced_by, flagged_at, hidden_at, hidden_by, deleted_at
 FROM chirps
 WHERE id = $1
+  AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -72,18 +157,128 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getChirpTombstone = `-- name: GetChirpTombstone :one
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.content_warning, c.sensitive, c.sensitive_forced_by, c.flagged_at, c.hidden_at, c.hidden_by, c.deleted_at
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = $1
+  AND c.deleted_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = $2)
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = $2
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = $2
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = $2)
+  AND (
+    c.user_id = $2
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+`
+
+type GetChirpTombstoneParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpTombstone(ctx context.Context, arg GetChirpTombstoneParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpTombstone, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-ORDER BY created_at ASC
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.content_warning, c.sensitive, c.sensitive_forced_by, c.flagged_at, c.hidden_at, c.hidden_by, c.deleted_at
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = $1 AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = $1)
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = $1
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = $1
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = $1)
+  AND (
+    c.user_id = $1
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM mutes m
+    WHERE m.muter_id = $1
+      AND m.muted_id = c.user_id
+)
+  AND (
+    NOT c.sensitive
+    OR c.user_id = $1
+    OR NOT EXISTS (
+        SELECT 1
+        FROM users v
+        WHERE v.id = $1
+          AND v.sensitive_media = 'hide'
+    )
+)
+ORDER BY c.created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +292,14 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PinnedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.SensitiveForcedBy,
+			&i.FlaggedAt,
+			&i.HiddenAt,
+			&i.HiddenBy,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -112,14 +315,121 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsAuthor = `-- name: GetChirpsAuthor :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.content_warning, c.sensitive, c.sensitive_forced_by, c.flagged_at, c.hidden_at, c.hidden_by, c.deleted_at
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.user_id = $1
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = $2)
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = $2
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = $2
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = $2)
+  AND (
+    c.user_id = $2
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM mutes m
+    WHERE m.muter_id = $2
+      AND m.muted_id = c.user_id
+)
+  AND (
+    NOT c.sensitive
+    OR c.user_id = $2
+    OR NOT EXISTS (
+        SELECT 1
+        FROM users v
+        WHERE v.id = $2
+          AND v.sensitive_media = 'hide'
+    )
+)
+ORDER BY c.pinned_at IS NOT NULL DESC, c.pinned_at DESC, c.created_at ASC
+`
+
+type GetChirpsAuthorParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsAuthor(ctx context.Context, arg GetChirpsAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsAuthor, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.PinnedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.SensitiveForcedBy,
+			&i.FlaggedAt,
+			&i.HiddenAt,
+			&i.HiddenBy,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsAuthorPage = `-- name: GetChirpsAuthorPage :many
+SELECT id, created_at, updated_at, body, user_id, pinned_at, content_warning, sensitive, sensitive_forced_by, flagged_at, hidden_at, hidden_by, deleted_at
 FROM chirps
 WHERE user_id = $1
-ORDER BY created_at ASC
+  AND deleted_at IS NULL
+  AND (created_at, id) > ($2::timestamp, $3::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $4::int
 `
 
-func (q *Queries) GetChirpsAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsAuthor, userID)
+type GetChirpsAuthorPageParams struct {
+	UserID         uuid.UUID
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	PageSize       int32
+}
+
+func (q *Queries) GetChirpsAuthorPage(ctx context.Context, arg GetChirpsAuthorPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsAuthorPage,
+		arg.UserID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +443,14 @@ func (q *Queries) GetChirpsAuthor(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PinnedAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.SensitiveForcedBy,
+			&i.FlaggedAt,
+			&i.HiddenAt,
+			&i.HiddenBy,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -146,3 +464,322 @@ func (q *Queries) GetChirpsAuthor(ctx context.Context, userID uuid.UUID) ([]Chir
 	}
 	return items, nil
 }
+
+const getListedChirp = `-- name: GetListedChirp :one
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.content_warning, c.sensitive, c.sensitive_forced_by, c.flagged_at, c.hidden_at, c.hidden_by, c.deleted_at
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = $1
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = $2)
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = $2
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = $2
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = $2)
+  AND (
+    c.user_id = $2
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM mutes m
+    WHERE m.muter_id = $2
+      AND m.muted_id = c.user_id
+)
+  AND (
+    NOT c.sensitive
+    OR c.user_id = $2
+    OR NOT EXISTS (
+        SELECT 1
+        FROM users v
+        WHERE v.id = $2
+          AND v.sensitive_media = 'hide'
+    )
+)
+`
+
+type GetListedChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetListedChirp(ctx context.Context, arg GetListedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getListedChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.content_warning, c.sensitive, c.sensitive_forced_by, c.flagged_at, c.hidden_at, c.hidden_by, c.deleted_at
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = $1
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = $2)
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = $2
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = $2
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = $2)
+  AND (
+    c.user_id = $2
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const pinChirp = `-- name: PinChirp :execrows
+UPDATE chirps
+SET pinned_at = NOW()
+WHERE id = $1
+  AND user_id = $2
+  AND pinned_at IS NULL
+  AND (
+    SELECT COUNT(*)
+    FROM chirps p
+    WHERE p.user_id = $2
+      AND p.pinned_at IS NOT NULL
+) < $3::int
+`
+
+type PinChirpParams struct {
+	ID      uuid.UUID
+	UserID  uuid.UUID
+	MaxPins int32
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pinChirp, arg.ID, arg.UserID, arg.MaxPins)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1
+  AND user_id = $2
+  AND deleted_at > $3
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, content_warning, sensitive, sensitive_forced_by, flagged_at, hidden_at, hidden_by, deleted_at
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW(),
+    pinned_at = NULL
+WHERE id = $1
+  AND user_id = $2
+  AND deleted_at IS NULL
+`
+
+type SoftDeleteChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteChirpsOfPurgedUsers = `-- name: SoftDeleteChirpsOfPurgedUsers :exec
+UPDATE chirps c
+SET deleted_at = NOW(),
+    pinned_at = NULL
+FROM users u
+WHERE u.id = c.user_id
+  AND u.deletion_requested_at IS NOT NULL
+  AND u.deletion_requested_at <= $1::timestamp
+  AND c.deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteChirpsOfPurgedUsers(ctx context.Context, cutoff time.Time) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirpsOfPurgedUsers, cutoff)
+	return err
+}
+
+const unpinChirp = `-- name: UnpinChirp :execrows
+UPDATE chirps
+SET pinned_at = NULL
+WHERE id = $1
+  AND user_id = $2
+`
+
+type UnpinChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unpinChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $1,
+    content_warning = $2,
+    sensitive = $3 OR sensitive_forced_by IS NOT NULL,
+    flagged_at = CASE WHEN $4::bool THEN COALESCE(flagged_at, NOW()) ELSE flagged_at END,
+    updated_at = NOW()
+WHERE id = $5
+  AND user_id = $6
+  AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, content_warning, sensitive, sensitive_forced_by, flagged_at, hidden_at, hidden_by, deleted_at
+`
+
+type UpdateChirpParams struct {
+	Body           string
+	ContentWarning string
+	Sensitive      bool
+	Flagged        bool
+	ID             uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp,
+		arg.Body,
+		arg.ContentWarning,
+		arg.Sensitive,
+		arg.Flagged,
+		arg.ID,
+		arg.UserID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.SensitiveForcedBy,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.DeletedAt,
+	)
+	return i, err
+}
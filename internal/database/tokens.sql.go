@@ -66,25 +66,39 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT id, u.created_at, u.updated_at, email, hashed_password, is_chirpy_red, token, rt.created_at, rt.updated_at, user_id, expires_at, revoked_at
+SELECT id, u.created_at, u.updated_at, email, hashed_password, is_chirpy_red, deletion_requested_at, display_name, bio, location, website, avatar_key, is_protected, sensitive_media, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at, username, token, rt.created_at, rt.updated_at, user_id, expires_at, revoked_at
 FROM users u
 JOIN refresh_tokens rt ON rt.user_id = u.id
 WHERE rt.token = $1
 `
 
 type GetUserFromRefreshTokenRow struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
-	Token          string
-	CreatedAt_2    time.Time
-	UpdatedAt_2    time.Time
-	UserID         uuid.UUID
-	ExpiresAt      time.Time
-	RevokedAt      sql.NullTime
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	DeletionRequestedAt sql.NullTime
+	DisplayName         string
+	Bio                 string
+	Location            string
+	Website             string
+	AvatarKey           sql.NullString
+	IsProtected         bool
+	SensitiveMedia      string
+	Role                string
+	SuspendedAt         sql.NullTime
+	SuspendedUntil      sql.NullTime
+	SuspensionReason    string
+	ShadowBannedAt      sql.NullTime
+	Username            sql.NullString
+	Token               string
+	CreatedAt_2         time.Time
+	UpdatedAt_2         time.Time
+	UserID              uuid.UUID
+	ExpiresAt           time.Time
+	RevokedAt           sql.NullTime
 }
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
@@ -97,6 +111,20 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletionRequestedAt,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.IsProtected,
+		&i.SensitiveMedia,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+		&i.Username,
 		&i.Token,
 		&i.CreatedAt_2,
 		&i.UpdatedAt_2,
@@ -107,6 +135,43 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 	return i, err
 }
 
+const getUserRefreshTokens = `-- name: GetUserRefreshTokens :many
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getUserRefreshTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.Token,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const postRevokeRefreshToken = `-- name: PostRevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET
@@ -119,3 +184,17 @@ func (q *Queries) PostRevokeRefreshToken(ctx context.Context, token string) erro
 	_, err := q.db.ExecContext(ctx, postRevokeRefreshToken, token)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET
+    updated_at = NOW(),
+    revoked_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
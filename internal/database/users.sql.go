@@ -7,10 +7,24 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -20,7 +34,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_requested_at, display_name, bio, location, website, avatar_key, is_protected, sensitive_media, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at, username
 `
 
 type CreateUserParams struct {
@@ -38,12 +52,83 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletionRequestedAt,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.IsProtected,
+		&i.SensitiveMedia,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+		&i.Username,
+	)
+	return i, err
+}
+
+const getPublicProfile = `-- name: GetPublicProfile :one
+SELECT
+    u.id,
+    u.created_at,
+    u.username,
+    u.display_name,
+    u.bio,
+    u.location,
+    u.website,
+    u.avatar_key,
+    u.is_chirpy_red,
+    u.is_protected,
+    (SELECT COUNT(*) FROM follows f WHERE f.followee_id = u.id AND f.status = 'accepted') AS follower_count,
+    (SELECT COUNT(*) FROM follows f WHERE f.follower_id = u.id AND f.status = 'accepted') AS following_count,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id AND c.deleted_at IS NULL) AS chirp_count
+FROM users u
+WHERE u.id = $1
+  AND u.deletion_requested_at IS NULL
+`
+
+type GetPublicProfileRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	Username       sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
+	Website        string
+	AvatarKey      sql.NullString
+	IsChirpyRed    bool
+	IsProtected    bool
+	FollowerCount  int64
+	FollowingCount int64
+	ChirpCount     int64
+}
+
+func (q *Queries) GetPublicProfile(ctx context.Context, id uuid.UUID) (GetPublicProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, getPublicProfile, id)
+	var i GetPublicProfileRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.ChirpCount,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_requested_at, display_name, bio, location, website, avatar_key, is_protected, sensitive_media, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at, username
 FROM users
 WHERE email = $1
 `
@@ -58,10 +143,152 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletionRequestedAt,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.IsProtected,
+		&i.SensitiveMedia,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+		&i.Username,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_requested_at, display_name, bio, location, website, avatar_key, is_protected, sensitive_media, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at, username
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionRequestedAt,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.IsProtected,
+		&i.SensitiveMedia,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+		&i.Username,
 	)
 	return i, err
 }
 
+const getUserByIDForUpdate = `-- name: GetUserByIDForUpdate :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_requested_at, display_name, bio, location, website, avatar_key, is_protected, sensitive_media, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at, username
+FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIDForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionRequestedAt,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.IsProtected,
+		&i.SensitiveMedia,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+		&i.Username,
+	)
+	return i, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deletion_requested_at IS NOT NULL
+  AND deletion_requested_at <= $1::timestamp
+RETURNING avatar_key
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, cutoff time.Time) ([]sql.NullString, error) {
+	rows, err := q.db.QueryContext(ctx, purgeDeletedUsers, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullString
+	for rows.Next() {
+		var avatar_key sql.NullString
+		if err := rows.Scan(&avatar_key); err != nil {
+			return nil, err
+		}
+		items = append(items, avatar_key)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requestUserDeletion = `-- name: RequestUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, requestUserDeletion, id)
+	return err
+}
+
+const updateUserAvatar = `-- name: UpdateUserAvatar :exec
+UPDATE users
+SET avatar_key = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserAvatarParams struct {
+	ID        uuid.UUID
+	AvatarKey sql.NullString
+}
+
+func (q *Queries) UpdateUserAvatar(ctx context.Context, arg UpdateUserAvatarParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserAvatar, arg.ID, arg.AvatarKey)
+	return err
+}
+
 const updateUserChirpyRed = `-- name: UpdateUserChirpyRed :exec
 UPDATE users
 SET is_chirpy_red = TRUE
@@ -75,7 +302,8 @@ func (q *Queries) UpdateUserChirpyRed(ctx context.Context, id uuid.UUID) error {
 
 const updateUserEmail = `-- name: UpdateUserEmail :exec
 UPDATE users
-SET email = $2
+SET email = $2,
+    updated_at = NOW()
 WHERE id = $1
 `
 
@@ -91,7 +319,8 @@ func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams
 
 const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE users
-SET hashed_password = $2
+SET hashed_password = $2,
+    updated_at = NOW()
 WHERE id = $1
 `
 
@@ -104,3 +333,160 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
 	return err
 }
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET display_name = $2,
+    bio = $3,
+    location = $4,
+    website = $5,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_requested_at, display_name, bio, location, website, avatar_key, is_protected, sensitive_media, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at, username
+`
+
+type UpdateUserProfileParams struct {
+	ID          uuid.UUID
+	DisplayName string
+	Bio         string
+	Location    string
+	Website     string
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.ID,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionRequestedAt,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.IsProtected,
+		&i.SensitiveMedia,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+		&i.Username,
+	)
+	return i, err
+}
+
+const updateUserProtected = `-- name: UpdateUserProtected :exec
+UPDATE users
+SET is_protected = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserProtectedParams struct {
+	ID          uuid.UUID
+	IsProtected bool
+}
+
+func (q *Queries) UpdateUserProtected(ctx context.Context, arg UpdateUserProtectedParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserProtected, arg.ID, arg.IsProtected)
+	return err
+}
+
+const updateUserSensitiveMedia = `-- name: UpdateUserSensitiveMedia :exec
+UPDATE users
+SET sensitive_media = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserSensitiveMediaParams struct {
+	ID             uuid.UUID
+	SensitiveMedia string
+}
+
+func (q *Queries) UpdateUserSensitiveMedia(ctx context.Context, arg UpdateUserSensitiveMediaParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserSensitiveMedia, arg.ID, arg.SensitiveMedia)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET suspended_at = NOW(),
+    suspended_until = $2,
+    suspension_reason = $3,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SuspendUserParams struct {
+	ID               uuid.UUID
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+	ShadowBannedAt   sql.NullTime
+	Username         sql.NullString
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil, arg.SuspensionReason)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :exec
+UPDATE users
+SET suspended_at = NULL,
+    suspended_until = NULL,
+    suspension_reason = '',
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	return err
+}
+
+const setUserShadowBanned = `-- name: SetUserShadowBanned :exec
+UPDATE users
+SET shadow_banned_at = CASE WHEN $1::bool THEN COALESCE(shadow_banned_at, NOW()) END,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type SetUserShadowBannedParams struct {
+	ShadowBanned bool
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetUserShadowBanned(ctx context.Context, arg SetUserShadowBannedParams) error {
+	_, err := q.db.ExecContext(ctx, setUserShadowBanned, arg.ShadowBanned, arg.ID)
+	return err
+}
+
+const updateUsername = `-- name: UpdateUsername :exec
+UPDATE users
+SET username = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUsernameParams struct {
+	ID       uuid.UUID
+	Username sql.NullString
+}
+
+func (q *Queries) UpdateUsername(ctx context.Context, arg UpdateUsernameParams) error {
+	_, err := q.db.ExecContext(ctx, updateUsername, arg.ID, arg.Username)
+	return err
+}
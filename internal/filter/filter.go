@@ -0,0 +1,262 @@
+// Package filter matches text against a configurable list of banned words.
+//
+// Matching is done word by word on a normalised form of the text: runes
+// are Unicode case-folded, common leetspeak substitutions ("sh4rb3rt",
+// "f0rn@x") are undone, look-alike characters such as "l", "1" and "!" are
+// treated as the same letter, and punctuation around or inside a word is
+// ignored, so "Kerfuffle!" and "f.o.r.n.a.x" both match.
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Action is what happens to text containing a word.
+type Action string
+
+const (
+	// ActionMask replaces the word with asterisks.
+	ActionMask Action = "mask"
+	// ActionReject refuses the text outright.
+	ActionReject Action = "reject"
+	// ActionFlag lets the text through unchanged but marks it for review
+	// by a moderator.
+	ActionFlag Action = "flag"
+)
+
+const mask = "****"
+
+var ErrInvalidRule = errors.New("invalid filter rule")
+
+type Rule struct {
+	Word   string `json:"word"`
+	Action Action `json:"action"`
+}
+
+// Config is the on-disk format of a word list.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Result describes what Check found in a piece of text.
+type Result struct {
+	// Text is the input with every masked word replaced.
+	Text     string
+	Rejected bool
+	Flagged  bool
+	// Matches lists the rule words that matched, in order of appearance.
+	Matches []string
+}
+
+// Filter is safe for concurrent use. Its rules can be changed or reloaded
+// while it is being used.
+type Filter struct {
+	path string
+
+	mu    sync.RWMutex
+	rules map[string]Rule // keyed by normalised word
+}
+
+// New returns a Filter with the given rules that is not backed by a file.
+func New(rules []Rule) (*Filter, error) {
+	f := &Filter{}
+	err := f.setRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Load returns a Filter backed by the JSON config file at path. If the file
+// doesn't exist yet the filter starts with defaults, and the file is
+// created the first time the rules are changed.
+func Load(path string, defaults []Rule) (*Filter, error) {
+	f := &Filter{path: path}
+	err := f.Reload()
+	if errors.Is(err, os.ErrNotExist) {
+		err = f.setRules(defaults)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload re-reads the config file, replacing the current rules. It is a
+// no-op for filters that aren't backed by a file.
+func (f *Filter) Reload() error {
+	if f.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	var cfg Config
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", f.path, err)
+	}
+	return f.setRules(cfg.Rules)
+}
+
+// Rules returns the current rules sorted by word.
+func (f *Filter) Rules() []Rule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	rules := make([]Rule, 0, len(f.rules))
+	for _, rule := range f.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Word < rules[j].Word
+	})
+	return rules
+}
+
+// SetRule adds a rule, or changes the action of an existing one, and
+// saves the config file.
+func (f *Filter) SetRule(rule Rule) error {
+	rule.Word = strings.TrimSpace(rule.Word)
+	key := normalize(rule.Word)
+	if key == "" || !rule.Action.valid() {
+		return ErrInvalidRule
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules[key] = rule
+	return f.saveLocked()
+}
+
+// RemoveRule deletes the rule for word, reporting whether there was one,
+// and saves the config file.
+func (f *Filter) RemoveRule(word string) (bool, error) {
+	key := normalize(word)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.rules[key]; !ok {
+		return false, nil
+	}
+	delete(f.rules, key)
+	return true, f.saveLocked()
+}
+
+// Check runs text through the filter.
+func (f *Filter) Check(text string) Result {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	result := Result{}
+	var out strings.Builder
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		out.WriteString(f.checkWordLocked(text[start:end], &result))
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			flush(i)
+			out.WriteRune(r)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	flush(len(text))
+	result.Text = out.String()
+	return result
+}
+
+// checkWordLocked looks up a single whitespace-delimited word, records any
+// match in result and returns the word as it should appear in the output.
+func (f *Filter) checkWordLocked(word string, result *Result) string {
+	// Try the word with surrounding punctuation removed first, so the
+	// punctuation survives masking ("Kerfuffle!" becomes "****!"), then the
+	// whole word, so leading or trailing leetspeak still matches ("$harbert").
+	rest := strings.TrimLeftFunc(word, isPunct)
+	core := strings.TrimRightFunc(rest, isPunct)
+	prefix, suffix := word[:len(word)-len(rest)], rest[len(core):]
+	rule, ok := f.rules[normalize(core)]
+	if !ok {
+		rule, ok = f.rules[normalize(word)]
+		prefix, suffix = "", ""
+	}
+	if !ok {
+		return word
+	}
+
+	result.Matches = append(result.Matches, rule.Word)
+	switch rule.Action {
+	case ActionReject:
+		result.Rejected = true
+	case ActionFlag:
+		result.Flagged = true
+		return word
+	}
+	return prefix + mask + suffix
+}
+
+func (f *Filter) setRules(rules []Rule) error {
+	m := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		rule.Word = strings.TrimSpace(rule.Word)
+		key := normalize(rule.Word)
+		if key == "" || !rule.Action.valid() {
+			return fmt.Errorf("%w: %q", ErrInvalidRule, rule.Word)
+		}
+		m[key] = rule
+	}
+	f.mu.Lock()
+	f.rules = m
+	f.mu.Unlock()
+	return nil
+}
+
+// saveLocked writes the rules to the config file, replacing it atomically.
+func (f *Filter) saveLocked() error {
+	if f.path == "" {
+		return nil
+	}
+	cfg := Config{Rules: make([]Rule, 0, len(f.rules))}
+	for _, rule := range f.rules {
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+	sort.Slice(cfg.Rules, func(i, j int) bool {
+		return cfg.Rules[i].Word < cfg.Rules[j].Word
+	})
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(data, '\n'))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (a Action) valid() bool {
+	return a == ActionMask || a == ActionReject || a == ActionFlag
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testRules = []Rule{
+	{Word: "kerfuffle", Action: ActionMask},
+	{Word: "sharbert", Action: ActionMask},
+	{Word: "fornax", Action: ActionReject},
+	{Word: "grumbletoad", Action: ActionFlag},
+}
+
+func TestCheckMasks(t *testing.T) {
+	f, err := New(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"I had a kerfuffle today", "I had a **** today"},
+		{"Kerfuffle! What a day", "****! What a day"},
+		{"what a (sharbert), honestly", "what a (****), honestly"},
+		{"KERFUFFLE", "****"},
+		{"kerfuff1e and sh4rb3rt", "**** and ****"},
+		{"$harbert", "****"},
+		{"k.e.r.f.u.f.f.l.e", "****"},
+		{"\u212Aerfuffle", "****"},
+		{"Kerfuffle", "****"},
+		{"kerfuffles are fine", "kerfuffles are fine"},
+		{"keep  the\tspacing", "keep  the\tspacing"},
+	}
+	for _, tt := range tests {
+		got := f.Check(tt.in)
+		if got.Text != tt.want {
+			t.Errorf("Check(%q).Text = %q, want %q", tt.in, got.Text, tt.want)
+		}
+		if got.Rejected || got.Flagged {
+			t.Errorf("Check(%q) rejected=%v flagged=%v, want neither", tt.in, got.Rejected, got.Flagged)
+		}
+	}
+}
+
+func TestCheckActions(t *testing.T) {
+	f, err := New(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := f.Check("look at that f0rn@x!")
+	if !got.Rejected {
+		t.Error("expected text with a reject word to be rejected")
+	}
+	got = f.Check("what a Grumbletoad.")
+	if !got.Flagged || got.Rejected {
+		t.Errorf("got rejected=%v flagged=%v, want only flagged", got.Rejected, got.Flagged)
+	}
+	if got.Text != "what a Grumbletoad." {
+		t.Errorf("flagged text was changed to %q", got.Text)
+	}
+	if len(got.Matches) != 1 || got.Matches[0] != "grumbletoad" {
+		t.Errorf("Matches = %v, want [grumbletoad]", got.Matches)
+	}
+}
+
+func TestRulesPersistAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.json")
+	f, err := Load(path, testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("config file should not exist until rules change, got %v", err)
+	}
+	err = f.SetRule(Rule{Word: "Blorp", Action: ActionReject})
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := f.RemoveRule("KERFUFFLE")
+	if err != nil || !removed {
+		t.Fatalf("RemoveRule = %v, %v", removed, err)
+	}
+
+	g, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Check("blorp").Rejected {
+		t.Error("reloaded filter doesn't reject the added word")
+	}
+	if got := g.Check("kerfuffle").Text; got != "kerfuffle" {
+		t.Errorf("reloaded filter still masks a removed word: %q", got)
+	}
+
+	err = os.WriteFile(path, []byte(`{"rules":[{"word":"zonk","action":"mask"}]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := g.Rules(); len(rules) != 1 || rules[0].Word != "zonk" {
+		t.Errorf("Rules after reload = %v", rules)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	_, err := New([]Rule{{Word: "fine", Action: "explode"}})
+	if err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+	f, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.SetRule(Rule{Word: "...", Action: ActionMask})
+	if err == nil {
+		t.Error("expected an error for a rule that normalises to nothing")
+	}
+}
@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// lookalikes maps leetspeak substitutions and easily confused characters
+// to a single representative letter. Rule words go through the same
+// mapping, so "kerfuffle", "kerfuff1e" and "KERFUFF|E" all compare equal.
+var lookalikes = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'l': 'i',
+	'!': 'i',
+	'|': 'i',
+	'3': 'e',
+	'4': 'a',
+	'@': 'a',
+	'5': 's',
+	'$': 's',
+	'7': 't',
+	'+': 't',
+	'8': 'b',
+	'9': 'g',
+}
+
+// normalize returns the form of word used for matching.
+func normalize(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		r = fold(r)
+		if l, ok := lookalikes[r]; ok {
+			b.WriteRune(l)
+			continue
+		}
+		if isPunct(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// fold returns the canonical lower-case member of r's Unicode simple case
+// folding orbit, so that for example "K" (Kelvin sign), "K" and "k" fold
+// to the same rune.
+func fold(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return unicode.ToLower(min)
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
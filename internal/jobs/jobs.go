@@ -0,0 +1,330 @@
+// Package jobs runs background work from a Postgres-backed queue.
+//
+// Jobs are rows in the jobs table. Enqueue takes a *database.Queries, so a
+// job can be written in the same transaction as the change that calls for
+// it: if the transaction rolls back, the job was never queued, and if it
+// commits, the job will run even if the process dies straight afterwards.
+//
+// A Runner claims due jobs with FOR UPDATE SKIP LOCKED, so any number of
+// processes can work the same queue. A claimed job is leased; if its worker
+// dies, the job is claimed again once the lease runs out. An attempt's
+// outcome is only recorded while the job is still on that attempt, so a
+// worker that outlived its lease can't overwrite the next one's. Jobs that
+// fail are retried with backoff, and after MaxAttempts they're marked dead
+// and left for someone to look at.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+)
+
+const (
+	DefaultMaxAttempts = 10
+
+	backoffBase = 10 * time.Second
+	backoffMax  = time.Hour
+
+	// maxErrorLength caps how much of an error is kept in last_error.
+	maxErrorLength = 2000
+)
+
+// Job describes work to enqueue.
+type Job struct {
+	Kind string
+	// Payload is marshalled to JSON and passed to the kind's Handler. A
+	// nil Payload is sent as {}.
+	Payload any
+	// UniqueKey, if set, makes Enqueue a no-op while another job with the
+	// same key is queued or running.
+	UniqueKey string
+	// RunAt delays the job; the zero value runs it as soon as possible.
+	RunAt time.Time
+	// MaxAttempts defaults to DefaultMaxAttempts.
+	MaxAttempts int
+}
+
+// Enqueuer is implemented by *database.Queries.
+type Enqueuer interface {
+	EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (int64, error)
+}
+
+// Enqueue queues job. Pass the *database.Queries of a transaction to queue
+// it only if the transaction commits. It reports false if the job was
+// skipped because of its UniqueKey.
+func Enqueue(ctx context.Context, q Enqueuer, job Job) (bool, error) {
+	payload := []byte("{}")
+	if job.Payload != nil {
+		var err error
+		payload, err = json.Marshal(job.Payload)
+		if err != nil {
+			return false, fmt.Errorf("jobs: marshalling %s payload: %w", job.Kind, err)
+		}
+	}
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now().UTC()
+	}
+	maxAttempts := job.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	_, err := q.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        job.Kind,
+		Payload:     string(payload),
+		UniqueKey:   sql.NullString{String: job.UniqueKey, Valid: job.UniqueKey != ""},
+		MaxAttempts: int32(maxAttempts),
+		RunAt:       runAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// A Handler runs one job. Returning an error retries the job later, unless
+// the error is wrapped with Permanent. Handlers may run more than once for
+// the same job, so they should be idempotent.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the job is marked dead
+// straight away.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+type attemptKey struct{}
+
+// Attempt returns which attempt at its job a Handler's ctx belongs to,
+// counting from 1, so a handler can give up cleanly on its last one.
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+// Backoff returns how long to wait before retrying a job that has failed
+// attempt times: 10s, 20s, 40s and so on, up to an hour.
+func Backoff(attempt int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempt && d < backoffMax; i++ {
+		d *= 2
+	}
+	return min(d, backoffMax)
+}
+
+// Store is the part of *database.Queries a Runner uses.
+type Store interface {
+	Enqueuer
+	ClaimJobs(ctx context.Context, arg database.ClaimJobsParams) ([]database.Job, error)
+	CompleteJob(ctx context.Context, arg database.CompleteJobParams) (int64, error)
+	RetryJob(ctx context.Context, arg database.RetryJobParams) (int64, error)
+	KillJob(ctx context.Context, arg database.KillJobParams) (int64, error)
+}
+
+type periodicJob struct {
+	kind     string
+	interval time.Duration
+}
+
+// Runner works the queue. Register handlers with Handle and Every before
+// calling Run.
+type Runner struct {
+	store    Store
+	handlers map[string]Handler
+	periodic []periodicJob
+
+	// PollInterval is how often the queue is checked for due jobs.
+	PollInterval time.Duration
+	// Lease is how long a job may run before it's assumed lost and handed
+	// to another worker. Handlers' contexts are cancelled when it expires.
+	Lease time.Duration
+	// Concurrency is the most jobs run at once.
+	Concurrency int
+}
+
+// NewRunner returns a Runner with default settings.
+func NewRunner(store Store) *Runner {
+	return &Runner{
+		store:        store,
+		handlers:     map[string]Handler{},
+		PollInterval: 2 * time.Second,
+		Lease:        5 * time.Minute,
+		Concurrency:  8,
+	}
+}
+
+// Handle registers h to run jobs of the given kind.
+func (r *Runner) Handle(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+// Every runs fn about once per interval, as a job of the given kind. Only
+// one is ever queued at a time, however many processes are running, and
+// the first runs as soon as Run starts. fn handles its own errors; it's
+// not retried.
+func (r *Runner) Every(kind string, interval time.Duration, fn func(context.Context)) {
+	r.Handle(kind, func(ctx context.Context, _ json.RawMessage) error {
+		fn(ctx)
+		return nil
+	})
+	r.periodic = append(r.periodic, periodicJob{kind: kind, interval: interval})
+}
+
+// Run works the queue until ctx is cancelled, then waits for running jobs
+// to finish.
+func (r *Runner) Run(ctx context.Context) {
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	sem := make(chan struct{}, r.Concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	r.schedulePeriodic(ctx, 0)
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		if free := cap(sem) - len(sem); free > 0 {
+			jobs, err := r.store.ClaimJobs(ctx, database.ClaimJobsParams{
+				LeaseUntil: time.Now().UTC().Add(r.Lease),
+				Kinds:      kinds,
+				BatchSize:  int32(free),
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error claiming jobs: %s", err)
+			}
+			for _, job := range jobs {
+				sem <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+					// Finish the job even if ctx is cancelled meanwhile,
+					// so it isn't left running until its lease expires.
+					r.run(context.WithoutCancel(ctx), job)
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.schedulePeriodic(ctx, -1)
+	}
+}
+
+// schedulePeriodic makes sure each periodic job is queued, to run after
+// delay, or after its interval if delay is negative.
+func (r *Runner) schedulePeriodic(ctx context.Context, delay time.Duration) {
+	for _, p := range r.periodic {
+		d := delay
+		if d < 0 {
+			d = p.interval
+		}
+		_, err := Enqueue(ctx, r.store, Job{
+			Kind:        p.kind,
+			UniqueKey:   "periodic:" + p.kind,
+			RunAt:       time.Now().UTC().Add(d),
+			MaxAttempts: 1,
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error scheduling %s job: %s", p.kind, err)
+		}
+	}
+}
+
+// run runs a claimed job and records the outcome.
+func (r *Runner) run(ctx context.Context, job database.Job) {
+	var err error
+	if job.Attempts > job.MaxAttempts {
+		// Its last attempt's worker died before recording the outcome.
+		err = Permanent(errors.New("lease expired on the final attempt"))
+	} else {
+		err = r.call(ctx, job)
+	}
+
+	if err == nil {
+		n, err := r.store.CompleteJob(ctx, database.CompleteJobParams{
+			ID:       job.ID,
+			Attempts: job.Attempts,
+		})
+		if err != nil {
+			log.Printf("Error completing job %d: %s", job.ID, err)
+		} else if n == 0 {
+			logLostLease(job)
+		}
+		return
+	}
+
+	msg := err.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	var n int64
+	if errors.As(err, new(permanentError)) || job.Attempts >= job.MaxAttempts {
+		log.Printf("Job %d (%s) failed for good after %d attempts: %s", job.ID, job.Kind, job.Attempts, msg)
+		n, err = r.store.KillJob(ctx, database.KillJobParams{
+			ID:        job.ID,
+			Attempts:  job.Attempts,
+			LastError: msg,
+		})
+	} else {
+		log.Printf("Job %d (%s) failed, will retry: %s", job.ID, job.Kind, msg)
+		n, err = r.store.RetryJob(ctx, database.RetryJobParams{
+			ID:        job.ID,
+			Attempts:  job.Attempts,
+			RunAt:     time.Now().UTC().Add(Backoff(int(job.Attempts))),
+			LastError: msg,
+		})
+	}
+	if err != nil {
+		log.Printf("Error recording failure of job %d: %s", job.ID, err)
+	} else if n == 0 {
+		logLostLease(job)
+	}
+}
+
+// logLostLease reports an attempt whose outcome was discarded because its
+// lease ran out and the job was claimed again, or requeued by an admin.
+func logLostLease(job database.Job) {
+	log.Printf("Job %d (%s) lost its lease during attempt %d; its outcome was discarded", job.ID, job.Kind, job.Attempts)
+}
+
+// call runs job's handler, turning panics into errors.
+func (r *Runner) call(ctx context.Context, job database.Job) (err error) {
+	h, ok := r.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, r.Lease)
+	defer cancel()
+	ctx = context.WithValue(ctx, attemptKey{}, int(job.Attempts))
+	return h(ctx, json.RawMessage(job.Payload))
+}
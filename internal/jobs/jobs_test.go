@@ -0,0 +1,268 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+)
+
+// fakeStore records what the Runner does with jobs. If attempts is set,
+// it's the job's current attempt, and outcomes of any other attempt are
+// ignored the way the database ignores them.
+type fakeStore struct {
+	enqueued  []database.EnqueueJobParams
+	unique    map[string]bool
+	attempts  int32
+	completed []int64
+	retried   []database.RetryJobParams
+	killed    []database.KillJobParams
+}
+
+func (s *fakeStore) current(attempts int32) bool {
+	return s.attempts == 0 || s.attempts == attempts
+}
+
+func (s *fakeStore) EnqueueJob(_ context.Context, arg database.EnqueueJobParams) (int64, error) {
+	if arg.UniqueKey.Valid {
+		if s.unique[arg.UniqueKey.String] {
+			return 0, sql.ErrNoRows
+		}
+		if s.unique == nil {
+			s.unique = map[string]bool{}
+		}
+		s.unique[arg.UniqueKey.String] = true
+	}
+	s.enqueued = append(s.enqueued, arg)
+	return int64(len(s.enqueued)), nil
+}
+
+func (s *fakeStore) ClaimJobs(context.Context, database.ClaimJobsParams) ([]database.Job, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) CompleteJob(_ context.Context, arg database.CompleteJobParams) (int64, error) {
+	if !s.current(arg.Attempts) {
+		return 0, nil
+	}
+	s.completed = append(s.completed, arg.ID)
+	return 1, nil
+}
+
+func (s *fakeStore) RetryJob(_ context.Context, arg database.RetryJobParams) (int64, error) {
+	if !s.current(arg.Attempts) {
+		return 0, nil
+	}
+	s.retried = append(s.retried, arg)
+	return 1, nil
+}
+
+func (s *fakeStore) KillJob(_ context.Context, arg database.KillJobParams) (int64, error) {
+	if !s.current(arg.Attempts) {
+		return 0, nil
+	}
+	s.killed = append(s.killed, arg)
+	return 1, nil
+}
+
+func TestEnqueue(t *testing.T) {
+	store := &fakeStore{}
+	ok, err := Enqueue(context.Background(), store, Job{
+		Kind:      "greet",
+		Payload:   map[string]string{"name": "chirpy"},
+		UniqueKey: "greet:chirpy",
+	})
+	if err != nil || !ok {
+		t.Fatalf("Enqueue = %v, %v; want true, nil", ok, err)
+	}
+	got := store.enqueued[0]
+	if got.Payload != `{"name":"chirpy"}` {
+		t.Errorf("Payload = %s", got.Payload)
+	}
+	if got.MaxAttempts != DefaultMaxAttempts {
+		t.Errorf("MaxAttempts = %d, want %d", got.MaxAttempts, DefaultMaxAttempts)
+	}
+	if time.Since(got.RunAt) > time.Minute {
+		t.Errorf("RunAt = %s, want about now", got.RunAt)
+	}
+
+	ok, err = Enqueue(context.Background(), store, Job{Kind: "greet", UniqueKey: "greet:chirpy"})
+	if err != nil || ok {
+		t.Fatalf("duplicate Enqueue = %v, %v; want false, nil", ok, err)
+	}
+
+	_, err = Enqueue(context.Background(), store, Job{Kind: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := store.enqueued[1].Payload; p != "{}" {
+		t.Errorf("nil Payload was stored as %s, want {}", p)
+	}
+}
+
+func TestRunRecordsOutcomes(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := map[string]struct {
+		handler     Handler
+		attempts    int32
+		wantDone    bool
+		wantRetry   bool
+		wantKilled  bool
+		wantErrText string
+	}{
+		"success": {
+			handler:  func(context.Context, json.RawMessage) error { return nil },
+			attempts: 1,
+			wantDone: true,
+		},
+		"failure is retried": {
+			handler:     func(context.Context, json.RawMessage) error { return errBoom },
+			attempts:    1,
+			wantRetry:   true,
+			wantErrText: "boom",
+		},
+		"last attempt dead-letters": {
+			handler:     func(context.Context, json.RawMessage) error { return errBoom },
+			attempts:    3,
+			wantKilled:  true,
+			wantErrText: "boom",
+		},
+		"permanent error dead-letters": {
+			handler:     func(context.Context, json.RawMessage) error { return Permanent(errBoom) },
+			attempts:    1,
+			wantKilled:  true,
+			wantErrText: "boom",
+		},
+		"panic is retried": {
+			handler:     func(context.Context, json.RawMessage) error { panic("oops") },
+			attempts:    1,
+			wantRetry:   true,
+			wantErrText: "panic: oops",
+		},
+		"expired final lease dead-letters without running": {
+			handler: func(context.Context, json.RawMessage) error {
+				t.Error("handler ran after its final attempt")
+				return nil
+			},
+			attempts:    4,
+			wantKilled:  true,
+			wantErrText: "lease expired",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &fakeStore{}
+			r := NewRunner(store)
+			r.Handle("test", tt.handler)
+			r.run(context.Background(), database.Job{ID: 7, Kind: "test", Payload: "{}", Attempts: tt.attempts, MaxAttempts: 3})
+
+			if got := len(store.completed) == 1; got != tt.wantDone {
+				t.Errorf("completed = %v, want %v", got, tt.wantDone)
+			}
+			if got := len(store.retried) == 1; got != tt.wantRetry {
+				t.Errorf("retried = %v, want %v", got, tt.wantRetry)
+			}
+			if got := len(store.killed) == 1; got != tt.wantKilled {
+				t.Errorf("killed = %v, want %v", got, tt.wantKilled)
+			}
+			var lastError string
+			switch {
+			case tt.wantRetry:
+				lastError = store.retried[0].LastError
+				if wait := time.Until(store.retried[0].RunAt); wait <= 0 || wait > Backoff(int(tt.attempts)) {
+					t.Errorf("retry scheduled %s from now, want within %s", wait, Backoff(int(tt.attempts)))
+				}
+			case tt.wantKilled:
+				lastError = store.killed[0].LastError
+			}
+			if !strings.Contains(lastError, tt.wantErrText) {
+				t.Errorf("last error = %q, want it to mention %q", lastError, tt.wantErrText)
+			}
+		})
+	}
+}
+
+func TestRunAfterLostLease(t *testing.T) {
+	errBoom := errors.New("boom")
+	for name, h := range map[string]Handler{
+		"success": func(context.Context, json.RawMessage) error { return nil },
+		"failure": func(context.Context, json.RawMessage) error { return errBoom },
+		"permanent failure": func(context.Context, json.RawMessage) error {
+			return Permanent(errBoom)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Attempt 1 outran its lease and the job was claimed again.
+			store := &fakeStore{attempts: 2}
+			r := NewRunner(store)
+			r.Handle("test", h)
+			r.run(context.Background(), database.Job{ID: 7, Kind: "test", Payload: "{}", Attempts: 1, MaxAttempts: 3})
+			if len(store.completed)+len(store.retried)+len(store.killed) != 0 {
+				t.Errorf("stale attempt overwrote the job: %+v", store)
+			}
+		})
+	}
+}
+
+func TestRunPassesAttempt(t *testing.T) {
+	r := NewRunner(&fakeStore{})
+	got := 0
+	r.Handle("test", func(ctx context.Context, _ json.RawMessage) error {
+		got = Attempt(ctx)
+		return nil
+	})
+	r.run(context.Background(), database.Job{ID: 1, Kind: "test", Attempts: 2, MaxAttempts: 5})
+	if got != 2 {
+		t.Fatalf("Attempt = %d, want 2", got)
+	}
+}
+
+func TestRunUnknownKind(t *testing.T) {
+	store := &fakeStore{}
+	NewRunner(store).run(context.Background(), database.Job{ID: 1, Kind: "missing", Attempts: 1, MaxAttempts: 5})
+	if len(store.killed) != 1 {
+		t.Fatalf("a job with no handler should be dead-lettered, got %+v", store)
+	}
+}
+
+func TestSchedulePeriodic(t *testing.T) {
+	store := &fakeStore{}
+	r := NewRunner(store)
+	r.Every("tidy", time.Hour, func(context.Context) {})
+
+	r.schedulePeriodic(context.Background(), 0)
+	r.schedulePeriodic(context.Background(), -1)
+	if len(store.enqueued) != 1 {
+		t.Fatalf("enqueued %d periodic jobs, want 1 while one is pending", len(store.enqueued))
+	}
+	job := store.enqueued[0]
+	if job.UniqueKey.String != "periodic:tidy" || job.MaxAttempts != 1 {
+		t.Errorf("periodic job = %+v", job)
+	}
+	if time.Since(job.RunAt) > time.Minute {
+		t.Errorf("first run at %s, want about now", job.RunAt)
+	}
+
+	delete(store.unique, "periodic:tidy")
+	r.schedulePeriodic(context.Background(), -1)
+	if wait := time.Until(store.enqueued[1].RunAt); wait < 59*time.Minute {
+		t.Errorf("next run in %s, want about an hour", wait)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	if got := Backoff(100); got != backoffMax {
+		t.Errorf("Backoff(100) = %s, want the %s cap", got, backoffMax)
+	}
+}
@@ -0,0 +1,76 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// GIF block introducers.
+const (
+	gifExtension  = 0x21
+	gifImage      = 0x2c
+	gifTrailer    = 0x3b
+	gifColorTable = 0x80
+)
+
+// checkGIFFrames walks the blocks of a GIF without decoding any pixels and
+// rejects it once its frames exceed limits.MaxFrames, or their combined
+// area exceeds limits.MaxPixels. A malformed file is left for the decoder
+// to reject.
+func checkGIFFrames(data []byte, limits Limits) error {
+	// Header and logical screen descriptor.
+	if len(data) < 13 {
+		return ErrInvalidImage
+	}
+	i := 13 + colorTableSize(data[10])
+	frames, pixels := 0, 0
+	for i < len(data) {
+		switch data[i] {
+		case gifExtension:
+			i = skipSubBlocks(data, i+2)
+		case gifImage:
+			if i+10 > len(data) {
+				return nil
+			}
+			width := int(binary.LittleEndian.Uint16(data[i+5:]))
+			height := int(binary.LittleEndian.Uint16(data[i+7:]))
+			frames++
+			pixels += width * height
+			if frames > limits.MaxFrames || pixels > limits.MaxPixels {
+				return ErrTooLong
+			}
+			// Descriptor, local color table, then the LZW minimum code
+			// size ahead of the image data.
+			i += 10 + colorTableSize(data[i+9]) + 1
+			i = skipSubBlocks(data, i)
+		case gifTrailer:
+			return nil
+		default:
+			// Malformed; the decoder will reject it.
+			return nil
+		}
+	}
+	return nil
+}
+
+// colorTableSize returns the size in bytes of the color table announced by
+// a screen or image descriptor's packed flags.
+func colorTableSize(flags byte) int {
+	if flags&gifColorTable == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+// skipSubBlocks returns the index just past the chain of data sub-blocks
+// starting at i.
+func skipSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		n := int(data[i])
+		i++
+		if n == 0 {
+			break
+		}
+		i += n
+	}
+	return i
+}
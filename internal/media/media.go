@@ -0,0 +1,230 @@
+// Package media validates uploaded images, strips their metadata and
+// generates thumbnails.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrInvalidImage    = errors.New("invalid image")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+	ErrTooLong         = errors.New("animation is too long")
+)
+
+// Limits bounds the images Process accepts and the thumbnails it makes.
+// MaxFrames and MaxPixels apply to animations: every frame is decoded, so
+// a GIF's cost is the sum of its frames, not its canvas size.
+type Limits struct {
+	MaxWidth      int
+	MaxHeight     int
+	MaxFrames     int
+	MaxPixels     int
+	ThumbnailSize int
+}
+
+// Image is a processed upload. Data has had EXIF and other metadata
+// removed. Thumbnail is nil when none could be generated, in which case
+// clients should fall back to the full image.
+type Image struct {
+	ContentType          string
+	Width                int
+	Height               int
+	Data                 []byte
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Process validates data as an image of contentType and returns a copy
+// with metadata stripped along with a thumbnail.
+//
+// PNG, JPEG and GIF images are decoded and re-encoded, which drops every
+// metadata chunk; JPEG orientation is applied to the pixels first so
+// rotated photos still display the right way up. The standard library has
+// no WebP codec, so WebP files are validated and stripped at the container
+// level and get no thumbnail.
+func Process(contentType string, data []byte, limits Limits) (*Image, error) {
+	switch contentType {
+	case "image/png", "image/jpeg":
+		return processStill(contentType, data, limits)
+	case "image/gif":
+		return processGIF(data, limits)
+	case "image/webp":
+		return processWebP(data, limits)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+// checkConfig reads just the image header so oversized images are
+// rejected before their pixels are decoded.
+func checkConfig(data []byte, limits Limits) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrInvalidImage
+	}
+	return checkDimensions(cfg.Width, cfg.Height, limits)
+}
+
+func checkDimensions(width, height int, limits Limits) error {
+	if width <= 0 || height <= 0 {
+		return ErrInvalidImage
+	}
+	if width > limits.MaxWidth || height > limits.MaxHeight {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+func processStill(contentType string, data []byte, limits Limits) (*Image, error) {
+	err := checkConfig(data, limits)
+	if err != nil {
+		return nil, err
+	}
+	var img image.Image
+	if contentType == "image/png" {
+		img, err = png.Decode(bytes.NewReader(data))
+	} else {
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err == nil {
+			img = applyOrientation(img, jpegOrientation(data))
+		}
+	}
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	out := &Image{
+		ContentType:          contentType,
+		Width:                img.Bounds().Dx(),
+		Height:               img.Bounds().Dy(),
+		ThumbnailContentType: contentType,
+	}
+	out.Data, err = encode(contentType, img)
+	if err != nil {
+		return nil, err
+	}
+	out.Thumbnail, err = encode(contentType, thumbnail(img, limits.ThumbnailSize))
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func processGIF(data []byte, limits Limits) (*Image, error) {
+	err := checkConfig(data, limits)
+	if err != nil {
+		return nil, err
+	}
+	err = checkGIFFrames(data, limits)
+	if err != nil {
+		return nil, err
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(g.Image) == 0 {
+		return nil, ErrInvalidImage
+	}
+
+	// EncodeAll only writes the frames, their timing and the loop count,
+	// so comment and application extensions are dropped.
+	var buf bytes.Buffer
+	err = gif.EncodeAll(&buf, g)
+	if err != nil {
+		return nil, err
+	}
+	first := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	draw.Draw(first, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+	thumb, err := encode("image/png", thumbnail(first, limits.ThumbnailSize))
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		ContentType:          "image/gif",
+		Width:                g.Config.Width,
+		Height:               g.Config.Height,
+		Data:                 buf.Bytes(),
+		Thumbnail:            thumb,
+		ThumbnailContentType: "image/png",
+	}, nil
+}
+
+func processWebP(data []byte, limits Limits) (*Image, error) {
+	width, height, stripped, err := stripWebP(data)
+	if err != nil {
+		return nil, err
+	}
+	err = checkDimensions(width, height, limits)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		ContentType: "image/webp",
+		Width:       width,
+		Height:      height,
+		Data:        stripped,
+	}, nil
+}
+
+func encode(contentType string, img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnail scales img down so neither side exceeds size, averaging the
+// source pixels that fall under each destination pixel.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := size, size
+	if w > h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					sum[0] += int(p[0])
+					sum[1] += int(p[1])
+					sum[2] += int(p[2])
+					sum[3] += int(p[3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for i := range sum {
+				d[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
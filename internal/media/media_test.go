@@ -0,0 +1,193 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var testLimits = Limits{MaxWidth: 1000, MaxHeight: 1000, MaxFrames: 20, MaxPixels: 1 << 20, ThumbnailSize: 100}
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+func TestProcessPNGThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, testImage(400, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Process("image/png", buf.Bytes(), testLimits)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.Width != 400 || img.Height != 200 {
+		t.Errorf("got %dx%d, want 400x200", img.Width, img.Height)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("decoding thumbnail: %v", err)
+	}
+	if cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("thumbnail is %dx%d, want 100x50", cfg.Width, cfg.Height)
+	}
+}
+
+func TestProcessRejectsOversizedImage(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1001, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Process("image/png", buf.Bytes(), testLimits)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("got %v, want ErrTooLarge", err)
+	}
+}
+
+func TestProcessRejectsUnsupportedType(t *testing.T) {
+	_, err := Process("image/bmp", []byte("BM"), testLimits)
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("got %v, want ErrUnsupportedType", err)
+	}
+}
+
+func TestProcessJPEGStripsEXIFAndAppliesOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, testImage(40, 20), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A little-endian TIFF header with a single IFD entry: orientation 6
+	// (rotate 90 degrees clockwise).
+	tiff := []byte{'I', 'I', 0x2a, 0, 8, 0, 0, 0, 1, 0, 0x12, 0x01, 3, 0, 1, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0, 0}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	data := append([]byte{0xFF, 0xD8}, app1...)
+	data = append(data, segment...)
+	data = append(data, buf.Bytes()[2:]...)
+
+	img, err := Process("image/jpeg", data, testLimits)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.Width != 20 || img.Height != 40 {
+		t.Errorf("got %dx%d, want 20x40 after rotation", img.Width, img.Height)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Error("processed JPEG still contains EXIF data")
+	}
+}
+
+func webpChunk(fourCC string, payload []byte) []byte {
+	chunk := append([]byte(fourCC), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestProcessWebPStripsMetadata(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagEXIF | webpFlagXMP
+	vp8x[4] = 63 // width 64
+	vp8x[7] = 31 // height 32
+	vp8l := []byte{0x2f, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(vp8l[1:], 63|31<<14)
+
+	var body []byte
+	body = append(body, webpChunk("VP8X", vp8x)...)
+	body = append(body, webpChunk("VP8L", vp8l)...)
+	body = append(body, webpChunk("EXIF", []byte("secret GPS data"))...)
+	body = append(body, webpChunk("XMP ", []byte("<x:xmpmeta/>"))...)
+	data := append([]byte("RIFF\x00\x00\x00\x00WEBP"), body...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+
+	img, err := Process("image/webp", data, testLimits)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.Width != 64 || img.Height != 32 {
+		t.Errorf("got %dx%d, want 64x32", img.Width, img.Height)
+	}
+	if bytes.Contains(img.Data, []byte("EXIF")) || bytes.Contains(img.Data, []byte("xmpmeta")) {
+		t.Error("processed WebP still contains metadata chunks")
+	}
+	if img.Data[20]&(webpFlagEXIF|webpFlagXMP) != 0 {
+		t.Error("VP8X still advertises metadata")
+	}
+	if got := int(binary.LittleEndian.Uint32(img.Data[4:])); got != len(img.Data)-8 {
+		t.Errorf("RIFF size is %d, want %d", got, len(img.Data)-8)
+	}
+	if img.Thumbnail != nil {
+		t.Error("expected no WebP thumbnail")
+	}
+}
+
+func testGIF(t *testing.T, frames, w, h int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for range frames {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessGIF(t *testing.T) {
+	img, err := Process("image/gif", testGIF(t, 3, 400, 200), testLimits)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("decoding result: %v", err)
+	}
+	if len(g.Image) != 3 {
+		t.Errorf("got %d frames, want 3", len(g.Image))
+	}
+	if img.ThumbnailContentType != "image/png" || img.Thumbnail == nil {
+		t.Errorf("got thumbnail %q with %d bytes, want a PNG", img.ThumbnailContentType, len(img.Thumbnail))
+	}
+}
+
+func TestProcessRejectsLongGIF(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		frames int
+		size   int
+	}{
+		// Tiny frames, but more of them than allowed.
+		{"many frames", 5000, 1},
+		// Few frames within the canvas limit that add up to too many
+		// pixels.
+		{"many pixels", 5, 1000},
+	} {
+		_, err := Process("image/gif", testGIF(t, tc.frames, tc.size, tc.size), testLimits)
+		if !errors.Is(err, ErrTooLong) {
+			t.Errorf("%s: got %v, want ErrTooLong", tc.name, err)
+		}
+	}
+}
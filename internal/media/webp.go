@@ -0,0 +1,81 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// VP8X feature flags that announce metadata chunks.
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+// stripWebP walks a WebP container, reads the canvas size and returns a
+// copy with the EXIF and XMP chunks removed.
+func stripWebP(data []byte) (width, height int, out []byte, err error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, nil, ErrInvalidImage
+	}
+	riffSize := int(binary.LittleEndian.Uint32(data[4:]))
+	if riffSize+8 > len(data) || riffSize < 4 {
+		return 0, 0, nil, ErrInvalidImage
+	}
+	data = data[:riffSize+8]
+
+	out = make([]byte, 12, len(data))
+	copy(out, data[:12])
+	vp8xAt := -1
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return 0, 0, nil, ErrInvalidImage
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if size < 0 || i+8+size > len(data) {
+			return 0, 0, nil, ErrInvalidImage
+		}
+		end = min(end, len(data))
+		payload := data[i+8 : i+8+size]
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			i = end
+			continue
+		case "VP8X":
+			if len(payload) < 10 {
+				return 0, 0, nil, ErrInvalidImage
+			}
+			width = 1 + int(uint32(payload[4])|uint32(payload[5])<<8|uint32(payload[6])<<16)
+			height = 1 + int(uint32(payload[7])|uint32(payload[8])<<8|uint32(payload[9])<<16)
+			vp8xAt = len(out)
+		case "VP8 ":
+			if width == 0 {
+				if len(payload) < 10 || payload[3] != 0x9d || payload[4] != 0x01 || payload[5] != 0x2a {
+					return 0, 0, nil, ErrInvalidImage
+				}
+				width = int(binary.LittleEndian.Uint16(payload[6:]) & 0x3fff)
+				height = int(binary.LittleEndian.Uint16(payload[8:]) & 0x3fff)
+			}
+		case "VP8L":
+			if width == 0 {
+				if len(payload) < 5 || payload[0] != 0x2f {
+					return 0, 0, nil, ErrInvalidImage
+				}
+				bits := binary.LittleEndian.Uint32(payload[1:])
+				width = 1 + int(bits&0x3fff)
+				height = 1 + int((bits>>14)&0x3fff)
+			}
+		}
+		out = append(out, data[i:end]...)
+		i = end
+	}
+	if width == 0 || height == 0 {
+		return 0, 0, nil, ErrInvalidImage
+	}
+	if vp8xAt >= 0 {
+		out[vp8xAt+8] &^= webpFlagEXIF | webpFlagXMP
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return width, height, out, nil
+}
@@ -0,0 +1,177 @@
+// Package stream fans chirp events out to live subscribers.
+//
+// Events originate in Postgres: a trigger on the chirps table records each
+// change in chirp_events and announces it with NOTIFY. Every server
+// instance runs Listen, which relays those notifications into its own Hub,
+// so subscribers see every event no matter which instance handled the
+// write.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel chirp events are sent on.
+const Channel = "chirp_events"
+
+// Event kinds.
+const (
+	KindCreated = "created"
+	KindUpdated = "updated"
+	KindDeleted = "deleted"
+)
+
+// Event says that a chirp changed. It carries no content; subscribers load
+// the chirp themselves so they only see what their viewer is allowed to.
+type Event struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      string    `json:"kind"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+// Hub broadcasts events to its subscribers.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives the events published on a Hub.
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a new subscriber with room for buffer undelivered
+// events.
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		events: make(chan Event, buffer),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Events returns the channel events are delivered on. It is closed when
+// the subscription ends, either through Close or because the hub dropped
+// it.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Publish delivers e to every subscriber without blocking. Subscribers
+// that have fallen a full buffer behind are dropped; they're expected to
+// reconnect and catch up from chirp_events.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		select {
+		case s.events <- e:
+		default:
+			h.remove(s)
+		}
+	}
+}
+
+// Reset drops every subscriber, for when events may have been missed.
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	close(s.events)
+}
+
+// Audience answers whether a viewer was allowed to see the chirp an event
+// is about when the event happened. *database.Queries implements it.
+type Audience interface {
+	CouldSeeChirpEvent(ctx context.Context, arg database.CouldSeeChirpEventParams) (bool, error)
+}
+
+// TellDeleted reports whether viewerID should be told that the chirp in
+// the deletion e is gone. The chirp may no longer exist, for example after
+// a moderator removed it, so the answer comes from the audience recorded
+// with the event: only viewers who could have listed the chirp are told,
+// since anyone else would learn it existed.
+func TellDeleted(ctx context.Context, a Audience, viewerID uuid.UUID, e Event) (bool, error) {
+	if e.Kind != KindDeleted {
+		return false, nil
+	}
+	return a.CouldSeeChirpEvent(ctx, database.CouldSeeChirpEventParams{
+		ID:       e.ID,
+		ViewerID: viewerID,
+	})
+}
+
+// Listen relays notifications on Channel into hub until ctx is cancelled.
+// Notifications sent while the connection is down are lost, so after a
+// reconnect every subscriber is dropped to make it resume from the
+// database.
+func Listen(ctx context.Context, dbURL string, hub *Hub) error {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Chirp event listener: %s", err)
+		}
+	})
+	defer listener.Close()
+	err := listener.Listen(Channel)
+	if err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			if n == nil {
+				hub.Reset()
+				continue
+			}
+			var e Event
+			err := json.Unmarshal([]byte(n.Extra), &e)
+			if err != nil {
+				log.Printf("Error decoding chirp event %q: %s", n.Extra, err)
+				continue
+			}
+			hub.Publish(e)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestPublishReachesEverySubscriber(t *testing.T) {
+	hub := NewHub()
+	a := hub.Subscribe(4)
+	b := hub.Subscribe(4)
+	defer a.Close()
+	defer b.Close()
+
+	e := Event{ID: 1, Kind: KindCreated, ChirpID: uuid.New(), UserID: uuid.New()}
+	hub.Publish(e)
+	for name, s := range map[string]*Subscription{"a": a, "b": b} {
+		got, ok := <-s.Events()
+		if !ok || got != e {
+			t.Errorf("subscriber %s got %+v, %v; want %+v", name, got, ok, e)
+		}
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Subscribe(1)
+	fast := hub.Subscribe(4)
+	defer fast.Close()
+
+	hub.Publish(Event{ID: 1})
+	hub.Publish(Event{ID: 2})
+
+	got := <-slow.Events()
+	if got.ID != 1 {
+		t.Fatalf("slow subscriber got event %d first, want 1", got.ID)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("slow subscriber should have been dropped after its buffer filled")
+	}
+	for _, want := range []int64{1, 2} {
+		if got := <-fast.Events(); got.ID != want {
+			t.Fatalf("fast subscriber got event %d, want %d", got.ID, want)
+		}
+	}
+	// Closing a dropped subscription is harmless.
+	slow.Close()
+}
+
+func TestCloseAndReset(t *testing.T) {
+	hub := NewHub()
+	s := hub.Subscribe(1)
+	s.Close()
+	s.Close()
+	if _, ok := <-s.Events(); ok {
+		t.Fatal("closed subscription should have a closed channel")
+	}
+	hub.Publish(Event{ID: 1})
+
+	a := hub.Subscribe(1)
+	b := hub.Subscribe(1)
+	hub.Reset()
+	for _, s := range []*Subscription{a, b} {
+		if _, ok := <-s.Events(); ok {
+			t.Fatal("Reset should close every subscription")
+		}
+	}
+}
+
+// eventAudience knows who could see each event's chirp and nothing about
+// chirps themselves, like the database after a chirp is hard-deleted.
+type eventAudience map[int64][]uuid.UUID
+
+func (a eventAudience) CouldSeeChirpEvent(_ context.Context, arg database.CouldSeeChirpEventParams) (bool, error) {
+	for _, id := range a[arg.ID] {
+		if id == arg.ViewerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestRemovedChirpReachesSubscriber(t *testing.T) {
+	follower, stranger := uuid.New(), uuid.New()
+	hub := NewHub()
+	s := hub.Subscribe(4)
+	defer s.Close()
+
+	// A moderator's remove_chirp deletes the row outright; only the event
+	// is left to say who could see it.
+	removed := Event{ID: 7, Kind: KindDeleted, ChirpID: uuid.New(), UserID: uuid.New()}
+	hub.Publish(removed)
+	e := <-s.Events()
+
+	audience := eventAudience{removed.ID: {follower}}
+	for _, tc := range []struct {
+		name   string
+		viewer uuid.UUID
+		want   bool
+	}{
+		{"follower", follower, true},
+		{"stranger", stranger, false},
+		{"anonymous", uuid.Nil, false},
+	} {
+		got, err := TellDeleted(context.Background(), audience, tc.viewer, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("TellDeleted for %s = %v; want %v", tc.name, got, tc.want)
+		}
+	}
+
+	edited := Event{ID: 8, Kind: KindUpdated, ChirpID: removed.ChirpID}
+	audience[edited.ID] = []uuid.UUID{follower}
+	got, err := TellDeleted(context.Background(), audience, follower, edited)
+	if err != nil || got {
+		t.Errorf("TellDeleted for an edit = %v, %v; want false", got, err)
+	}
+}
@@ -0,0 +1,285 @@
+// Package webhooks sends signed event notifications to endpoints users
+// register.
+//
+// Each request carries a Chirpy-Signature header of the form
+// "t=<unix seconds>,v1=<hex HMAC-SHA256>", where the HMAC is keyed with the
+// endpoint's secret and covers "<t>.<body>". Receivers recompute it, and
+// reject old timestamps to stop replays; Verify does both.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types endpoints can subscribe to.
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpUpdated = "chirp.updated"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserUpgraded = "user.upgraded"
+)
+
+// EventTypes lists every event type, in the order they're documented.
+var EventTypes = []string{EventChirpCreated, EventChirpUpdated, EventChirpDeleted, EventUserUpgraded}
+
+// Request headers.
+const (
+	HeaderSignature = "Chirpy-Signature"
+	HeaderEvent     = "Chirpy-Event"
+	HeaderDelivery  = "Chirpy-Delivery"
+)
+
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 6 * time.Hour
+
+	// maxResponseBody is how much of a response is read before the
+	// connection is dropped; only the status code matters.
+	maxResponseBody = 64 << 10
+)
+
+var (
+	ErrInvalidSignature = errors.New("webhooks: invalid signature")
+	ErrPrivateAddress   = errors.New("webhooks: refusing to connect to a private address")
+)
+
+// ValidEventType reports whether t is one of EventTypes.
+func ValidEventType(t string) bool {
+	return slices.Contains(EventTypes, t)
+}
+
+// ValidateURL checks that raw is an absolute HTTPS URL an endpoint can be
+// registered at.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("URL is malformed")
+	}
+	if u.Scheme != "https" {
+		return errors.New("URL must use https")
+	}
+	if u.Hostname() == "" {
+		return errors.New("URL must have a host")
+	}
+	if u.User != nil {
+		return errors.New("URL must not contain credentials")
+	}
+	if u.Fragment != "" {
+		return errors.New("URL must not contain a fragment")
+	}
+	return nil
+}
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the Chirpy-Signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// Verify checks a Chirpy-Signature header against body, and that it was
+// signed within tolerance of now. Timestamps too far in the future are
+// rejected as well as old ones, so a signature can't be made to outlive
+// the tolerance by claiming a later time.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig, err := hex.DecodeString(v)
+			if err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp is outside the tolerance", ErrInvalidSignature)
+	}
+	want := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte{'.'})
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Backoff returns how long to wait before retrying a delivery that has
+// failed attempt times: 30s, 1m, 2m, 4m and so on, up to 6h.
+func Backoff(attempt int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempt && d < backoffMax; i++ {
+		d *= 2
+	}
+	return min(d, backoffMax)
+}
+
+// Request is one delivery attempt.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID uuid.UUID
+	EventType  string
+	Payload    []byte
+}
+
+// Result describes how an attempt went. StatusCode is 0 if no response
+// arrived. Err is set for anything but a 2xx response.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Sender delivers webhook requests.
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender returns a Sender whose requests time out after timeout and
+// don't follow redirects. Unless allowPrivate is set, it refuses to connect
+// to any address in privatePrefixes, so endpoints can't be used to reach
+// internal services.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	return &Sender{
+		Client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     time.Minute,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// privatePrefixes are the ranges a Sender won't connect to: everything
+// that isn't globally routable unicast, and the IPv6 ranges that embed an
+// IPv4 address and so could be translated into one of the others.
+// IPv4-mapped IPv6 addresses are unmapped before they're checked.
+var privatePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local, cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, broadcast
+	netip.MustParsePrefix("::/96"),           // unspecified, loopback, IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("fec0::/10"),       // site-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// refusePrivate runs just before each connection is made, once the host
+// has been resolved, so DNS tricks can't get around it.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return ErrPrivateAddress
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, p := range privatePrefixes {
+		if p.Contains(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// Send makes one signed delivery attempt.
+func (s *Sender) Send(ctx context.Context, req Request) Result {
+	start := time.Now()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	httpReq.Header.Set(HeaderEvent, req.EventType)
+	httpReq.Header.Set(HeaderDelivery, req.DeliveryID.String())
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, start, req.Payload))
+
+	resp, err := s.Client.Do(httpReq)
+	if err != nil {
+		return Result{Duration: time.Since(start), Err: err}
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	resp.Body.Close()
+
+	result := Result{StatusCode: resp.StatusCode, Duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Err = fmt.Errorf("webhooks: endpoint responded %s", resp.Status)
+	}
+	return result
+}
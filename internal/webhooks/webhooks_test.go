@@ -0,0 +1,195 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"chirp.created"}`)
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("Verify rejected a valid signature: %v", err)
+	}
+	tests := map[string]struct {
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+	}{
+		"wrong secret":     {"other", header, body, now},
+		"tampered body":    {"secret", header, []byte(`{"type":"chirp.deleted"}`), now},
+		"stale timestamp":  {"secret", header, body, now.Add(10 * time.Minute)},
+		"future timestamp": {"secret", header, body, now.Add(-10 * time.Minute)},
+		"missing v1":       {"secret", "t=1740830400", body, now},
+		"garbage":          {"secret", "nonsense", body, now},
+		"signature as hex": {"secret", "t=1740830400,v1=zz", body, now},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute)
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("Verify = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	if got := Backoff(50); got != backoffMax {
+		t.Errorf("Backoff(50) = %s, want the %s cap", got, backoffMax)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	valid := []string{"https://example.com/hooks", "https://example.com:8443/hooks?x=1"}
+	invalid := []string{"http://example.com/hooks", "https:///hooks", "https://user:pw@example.com/", "https://example.com/#frag", "example.com"}
+	for _, u := range valid {
+		if err := ValidateURL(u); err != nil {
+			t.Errorf("ValidateURL(%q) = %v, want nil", u, err)
+		}
+	}
+	for _, u := range invalid {
+		if err := ValidateURL(u); err == nil {
+			t.Errorf("ValidateURL(%q) should have failed", u)
+		}
+	}
+}
+
+func TestSendSignsRequests(t *testing.T) {
+	deliveryID := uuid.New()
+	payload := []byte(`{"type":"user.upgraded","data":{}}`)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != string(payload) {
+			t.Errorf("body = %s, want %s", body, payload)
+		}
+		if got := r.Header.Get(HeaderEvent); got != EventUserUpgraded {
+			t.Errorf("%s = %q, want %q", HeaderEvent, got, EventUserUpgraded)
+		}
+		if got := r.Header.Get(HeaderDelivery); got != deliveryID.String() {
+			t.Errorf("%s = %q, want %q", HeaderDelivery, got, deliveryID)
+		}
+		if err := Verify("whsec_test", r.Header.Get(HeaderSignature), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("signature didn't verify: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := &Sender{Client: srv.Client()}
+	result := s.Send(context.Background(), Request{
+		URL:        srv.URL,
+		Secret:     "whsec_test",
+		DeliveryID: deliveryID,
+		EventType:  EventUserUpgraded,
+		Payload:    payload,
+	})
+	if result.Err != nil || result.StatusCode != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v; want 204 and no error", result.StatusCode, result.Err)
+	}
+}
+
+func TestSendReportsFailures(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	client := srv.Client()
+	client.Timeout = 50 * time.Millisecond
+	client.CheckRedirect = NewSender(time.Second, true).Client.CheckRedirect
+	s := &Sender{Client: client}
+	tests := map[string]int{
+		"/error":    http.StatusInternalServerError,
+		"/redirect": http.StatusFound,
+		"/slow":     0,
+	}
+	for path, wantStatus := range tests {
+		t.Run(path, func(t *testing.T) {
+			result := s.Send(context.Background(), Request{URL: srv.URL + path, Secret: "s", EventType: EventChirpCreated})
+			if result.Err == nil {
+				t.Fatal("Send should have failed")
+			}
+			if result.StatusCode != wantStatus {
+				t.Fatalf("StatusCode = %d, want %d", result.StatusCode, wantStatus)
+			}
+		})
+	}
+}
+
+func TestNewSenderRefusesPrivateAddresses(t *testing.T) {
+	reached := false
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer srv.Close()
+
+	result := NewSender(time.Second, false).Send(context.Background(), Request{URL: srv.URL, Secret: "s", EventType: EventChirpCreated})
+	if !errors.Is(result.Err, ErrPrivateAddress) {
+		t.Fatalf("Send = %v, want ErrPrivateAddress", result.Err)
+	}
+	if reached {
+		t.Fatal("the request reached a loopback server")
+	}
+}
+
+func TestRefusePrivate(t *testing.T) {
+	refused := []string{
+		"127.0.0.1:443",
+		"10.1.2.3:443",
+		"100.64.0.1:443",
+		"0.0.0.0:443",
+		"169.254.169.254:80",
+		"198.18.0.1:443",
+		"255.255.255.255:443",
+		"[::1]:443",
+		"[::]:443",
+		"[::ffff:127.0.0.1]:443",
+		"[::ffff:10.0.0.1]:443",
+		"[::ffff:169.254.169.254]:80",
+		"[::127.0.0.1]:443",
+		"[64:ff9b::7f00:1]:443",
+		"[64:ff9b::a9fe:a9fe]:80",
+		"[2002:7f00:1::]:443",
+		"[fd00::1]:443",
+		"[fe80::1%eth0]:443",
+	}
+	for _, address := range refused {
+		if err := refusePrivate("tcp", address, nil); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("refusePrivate(%s) = %v, want ErrPrivateAddress", address, err)
+		}
+	}
+	allowed := []string{
+		"93.184.216.34:443",
+		"[::ffff:93.184.216.34]:443",
+		"[2606:2800:220:1:248:1893:25c8:1946]:443",
+	}
+	for _, address := range allowed {
+		if err := refusePrivate("tcp", address, nil); err != nil {
+			t.Errorf("refusePrivate(%s) = %v, want nil", address, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// farFuture is used as the "before" cursor when a client asks for the
+// first page.
+var farFuture = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parsePagination reads the ?limit=, ?before= and ?before_id= query
+// parameters shared by the paginated list endpoints. Lists are ordered
+// newest first with ties broken by ID, so the next page starts after the
+// timestamp (RFC 3339) and ID of the last item on the previous one; two
+// items with the same timestamp can't be skipped or repeated. Without
+// before_id, results strictly older than before are returned.
+func parsePagination(r *http.Request) (limit int32, before time.Time, beforeID uuid.UUID, err error) {
+	limit, before, err = parseLimitAndBefore(r)
+	if err != nil {
+		return 0, time.Time{}, uuid.Nil, err
+	}
+	if v := r.URL.Query().Get("before_id"); v != "" {
+		beforeID, err = uuid.Parse(v)
+		if err != nil {
+			return 0, time.Time{}, uuid.Nil, errors.New("before_id must be a UUID")
+		}
+	}
+	return limit, before, beforeID, nil
+}
+
+// parseSeqPagination is parsePagination for lists whose IDs are sequence
+// numbers rather than UUIDs.
+func parseSeqPagination(r *http.Request) (limit int32, before time.Time, beforeID int64, err error) {
+	limit, before, err = parseLimitAndBefore(r)
+	if err != nil {
+		return 0, time.Time{}, 0, err
+	}
+	if v := r.URL.Query().Get("before_id"); v != "" {
+		beforeID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || beforeID < 0 {
+			return 0, time.Time{}, 0, errors.New("before_id must be a non-negative integer")
+		}
+	}
+	return limit, before, beforeID, nil
+}
+
+func parseLimitAndBefore(r *http.Request) (limit int32, before time.Time, err error) {
+	limit = defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, time.Time{}, errors.New("limit must be a positive integer")
+		}
+		limit = int32(min(n, maxPageSize))
+	}
+	before = farFuture
+	if v := r.URL.Query().Get("before"); v != "" {
+		before, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return 0, time.Time{}, errors.New("before must be an RFC 3339 timestamp")
+		}
+	}
+	return limit, before, nil
+}
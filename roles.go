@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// User roles. Roles are granted directly in the database; there is no API
+// for promoting users.
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// authenticateModerator authenticates the caller and checks that they are
+// a moderator or an admin.
+func (api *apiConfig) authenticateModerator(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	return api.authenticateRole(w, r, roleModerator, roleAdmin)
+}
+
+// authenticateAdmin authenticates the caller and checks that they are an
+// admin.
+func (api *apiConfig) authenticateAdmin(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	return api.authenticateRole(w, r, roleAdmin)
+}
+
+func (api *apiConfig) authenticateRole(w http.ResponseWriter, r *http.Request, roles ...string) (uuid.UUID, bool) {
+	userID, err := api.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, false
+	}
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token")
+		return uuid.Nil, false
+	}
+	if !slices.Contains(roles, user.Role) {
+		respondWithError(w, http.StatusForbidden, "You don't have permission to do that")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
@@ -0,0 +1,43 @@
+-- name: LockAuditLog :exec
+SELECT pg_advisory_xact_lock(sqlc.arg(lock_key)::bigint);
+
+-- name: GetLatestAuditEvent :one
+SELECT *
+FROM audit_events
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (id, created_at, actor_id, action, target_type, target_id, ip, metadata, prev_hash, hash)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(created_at),
+    sqlc.arg(actor_id),
+    sqlc.arg(action),
+    sqlc.arg(target_type),
+    sqlc.arg(target_id),
+    sqlc.arg(ip),
+    sqlc.arg(metadata),
+    sqlc.arg(prev_hash),
+    sqlc.arg(hash)
+)
+RETURNING *;
+
+-- name: GetAuditEvents :many
+SELECT *
+FROM audit_events
+WHERE (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+  AND (sqlc.arg(action)::text = '' OR action = sqlc.arg(action))
+  AND (sqlc.arg(target_type)::text = '' OR target_type = sqlc.arg(target_type))
+  AND (sqlc.arg(target_id)::text = '' OR target_id = sqlc.arg(target_id))
+  AND created_at >= sqlc.arg(since)
+  AND (created_at, id) < (sqlc.arg(before)::timestamp, sqlc.arg(before_id)::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetAuditEventsAfter :many
+SELECT *
+FROM audit_events
+WHERE id > sqlc.arg(after_id)
+ORDER BY id ASC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,40 @@
+-- name: CreateBlock :exec
+WITH removed_follows AS (
+    DELETE FROM follows
+    WHERE (follower_id = sqlc.arg(blocker_id) AND followee_id = sqlc.arg(blocked_id))
+       OR (follower_id = sqlc.arg(blocked_id) AND followee_id = sqlc.arg(blocker_id))
+)
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    sqlc.arg(blocker_id),
+    sqlc.arg(blocked_id),
+    NOW()
+)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :exec
+DELETE FROM blocks
+WHERE blocker_id = $1
+  AND blocked_id = $2;
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_a) AND blocked_id = sqlc.arg(user_b))
+       OR (blocker_id = sqlc.arg(user_b) AND blocked_id = sqlc.arg(user_a))
+);
+
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteMute :exec
+DELETE FROM mutes
+WHERE muter_id = $1
+  AND muted_id = $2;
@@ -0,0 +1,92 @@
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.arg(name)
+)
+RETURNING *;
+
+-- name: GetBookmarkCollections :many
+SELECT *
+FROM bookmark_collections
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY name ASC;
+
+-- name: GetBookmarkCollection :one
+SELECT *
+FROM bookmark_collections
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: RenameBookmarkCollection :one
+UPDATE bookmark_collections
+SET name = sqlc.arg(name),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: DeleteBookmarkCollection :execrows
+DELETE FROM bookmark_collections
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: AddBookmark :exec
+INSERT INTO bookmarks (collection_id, chirp_id, created_at)
+VALUES (
+    sqlc.arg(collection_id),
+    sqlc.arg(chirp_id),
+    NOW()
+)
+ON CONFLICT (collection_id, chirp_id) DO NOTHING;
+
+-- name: RemoveBookmark :execrows
+DELETE FROM bookmarks
+WHERE collection_id = sqlc.arg(collection_id)
+  AND chirp_id = sqlc.arg(chirp_id);
+
+-- name: GetBookmarkedChirps :many
+SELECT sqlc.embed(c), bk.created_at AS bookmarked_at
+FROM bookmarks bk
+JOIN chirps c ON c.id = bk.chirp_id
+JOIN users u ON u.id = c.user_id
+WHERE bk.collection_id = sqlc.arg(collection_id)
+  AND (bk.created_at, bk.chirp_id) < (sqlc.arg(before)::timestamp, sqlc.arg(before_id)::uuid)
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id)
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = sqlc.arg(viewer_id))
+  AND (
+    c.user_id = sqlc.arg(viewer_id)
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+ORDER BY bk.created_at DESC, bk.chirp_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetBookmarkedChirpIDs :many
+SELECT DISTINCT bk.chirp_id
+FROM bookmarks bk
+JOIN bookmark_collections bc ON bc.id = bk.collection_id
+WHERE bc.user_id = sqlc.arg(user_id)
+  AND bk.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
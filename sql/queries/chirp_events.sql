@@ -0,0 +1,50 @@
+-- name: GetChirpEventsAfter :many
+SELECT *
+FROM chirp_events
+WHERE id > sqlc.arg(after_id)
+  AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+ORDER BY id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: CouldSeeChirpEvent :one
+SELECT COUNT(*) > 0 AS could_see
+FROM chirp_events e
+WHERE e.id = sqlc.arg(id)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = e.user_id)
+       OR (b.blocker_id = e.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+)
+  AND (
+    NOT e.author_protected
+    OR e.user_id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id)
+          AND f.followee_id = e.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (e.author_listed OR e.user_id = sqlc.arg(viewer_id))
+  AND NOT EXISTS (
+    SELECT 1
+    FROM mutes m
+    WHERE m.muter_id = sqlc.arg(viewer_id)
+      AND m.muted_id = e.user_id
+)
+  AND (
+    NOT e.sensitive
+    OR e.user_id = sqlc.arg(viewer_id)
+    OR NOT EXISTS (
+        SELECT 1
+        FROM users v
+        WHERE v.id = sqlc.arg(viewer_id)
+          AND v.sensitive_media = 'hide'
+    )
+);
+
+-- name: PurgeChirpEvents :execrows
+DELETE FROM chirp_events
+WHERE created_at < sqlc.arg(created_before);
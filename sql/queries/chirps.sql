@@ -1,32 +1,317 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, content_warning, sensitive, flagged_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1,
-    $2
+    sqlc.arg(body),
+    sqlc.arg(user_id),
+    sqlc.arg(content_warning),
+    sqlc.arg(sensitive),
+    CASE WHEN sqlc.arg(flagged)::bool THEN NOW() END
 )
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT *
-FROM chirps
-ORDER BY created_at ASC;
+SELECT c.*
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id)
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = sqlc.arg(viewer_id))
+  AND (
+    c.user_id = sqlc.arg(viewer_id)
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM mutes m
+    WHERE m.muter_id = sqlc.arg(viewer_id)
+      AND m.muted_id = c.user_id
+)
+  AND (
+    NOT c.sensitive
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR NOT EXISTS (
+        SELECT 1
+        FROM users v
+        WHERE v.id = sqlc.arg(viewer_id)
+          AND v.sensitive_media = 'hide'
+    )
+)
+ORDER BY c.created_at ASC;
 
 -- name: GetChirpsAuthor :many
-SELECT *
-FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
-
+SELECT c.*
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.user_id = sqlc.arg(user_id)
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id)
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = sqlc.arg(viewer_id))
+  AND (
+    c.user_id = sqlc.arg(viewer_id)
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM mutes m
+    WHERE m.muter_id = sqlc.arg(viewer_id)
+      AND m.muted_id = c.user_id
+)
+  AND (
+    NOT c.sensitive
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR NOT EXISTS (
+        SELECT 1
+        FROM users v
+        WHERE v.id = sqlc.arg(viewer_id)
+          AND v.sensitive_media = 'hide'
+    )
+)
+ORDER BY c.pinned_at IS NOT NULL DESC, c.pinned_at DESC, c.created_at ASC;
 
 -- name: GetChirp :one
 SELECT *
 FROM chirps
-WHERE id = $1;
+WHERE id = sqlc.arg(id)
+  AND deleted_at IS NULL;
+
+-- name: GetVisibleChirp :one
+SELECT c.*
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = sqlc.arg(id)
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id)
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = sqlc.arg(viewer_id))
+  AND (
+    c.user_id = sqlc.arg(viewer_id)
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+);
+
+-- name: GetListedChirp :one
+SELECT c.*
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = sqlc.arg(id)
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id)
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = sqlc.arg(viewer_id))
+  AND (
+    c.user_id = sqlc.arg(viewer_id)
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM mutes m
+    WHERE m.muter_id = sqlc.arg(viewer_id)
+      AND m.muted_id = c.user_id
+)
+  AND (
+    NOT c.sensitive
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR NOT EXISTS (
+        SELECT 1
+        FROM users v
+        WHERE v.id = sqlc.arg(viewer_id)
+          AND v.sensitive_media = 'hide'
+    )
+);
+
+-- name: GetChirpTombstone :one
+SELECT c.*
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = sqlc.arg(id)
+  AND c.deleted_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = sqlc.arg(viewer_id)
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = sqlc.arg(viewer_id))
+  AND (
+    c.user_id = sqlc.arg(viewer_id)
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+);
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE user_id = $1 
-  AND id = $2;
\ No newline at end of file
+WHERE user_id = sqlc.arg(user_id)
+  AND id = sqlc.arg(id);
+
+-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW(),
+    pinned_at = NULL
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at > sqlc.arg(deleted_after)
+RETURNING *;
+
+-- name: SoftDeleteChirpsOfPurgedUsers :exec
+UPDATE chirps c
+SET deleted_at = NOW(),
+    pinned_at = NULL
+FROM users u
+WHERE u.id = c.user_id
+  AND u.deletion_requested_at IS NOT NULL
+  AND u.deletion_requested_at <= sqlc.arg(cutoff)::timestamp
+  AND c.deleted_at IS NULL;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < sqlc.arg(deleted_before);
+
+-- name: GetChirpsAuthorPage :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND (created_at, id) > (sqlc.arg(after_created_at)::timestamp, sqlc.arg(after_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size)::int;
+
+-- name: PinChirp :execrows
+UPDATE chirps
+SET pinned_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND pinned_at IS NULL
+  AND (
+    SELECT COUNT(*)
+    FROM chirps p
+    WHERE p.user_id = sqlc.arg(user_id)
+      AND p.pinned_at IS NOT NULL
+) < sqlc.arg(max_pins)::int;
+
+-- name: UnpinChirp :execrows
+UPDATE chirps
+SET pinned_at = NULL
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: UpdateChirp :one
+UPDATE chirps
+SET body = sqlc.arg(body),
+    content_warning = sqlc.arg(content_warning),
+    sensitive = sqlc.arg(sensitive) OR sensitive_forced_by IS NOT NULL,
+    flagged_at = CASE WHEN sqlc.arg(flagged)::bool THEN COALESCE(flagged_at, NOW()) ELSE flagged_at END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ForceChirpSensitive :one
+UPDATE chirps
+SET sensitive = TRUE,
+    sensitive_forced_by = sqlc.arg(moderator_id)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ClearForcedChirpSensitive :one
+UPDATE chirps
+SET sensitive = FALSE,
+    sensitive_forced_by = NULL
+WHERE id = sqlc.arg(id)
+RETURNING *;
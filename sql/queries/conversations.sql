@@ -0,0 +1,124 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES (
+    sqlc.arg(conversation_id),
+    sqlc.arg(user_id),
+    NOW()
+);
+
+-- name: GetConversation :one
+SELECT c.*
+FROM conversations c
+JOIN conversation_participants p ON p.conversation_id = c.id
+WHERE c.id = sqlc.arg(id)
+  AND p.user_id = sqlc.arg(user_id);
+
+-- name: FindDirectConversation :one
+SELECT c.*
+FROM conversations c
+WHERE EXISTS (
+    SELECT 1
+    FROM conversation_participants p
+    WHERE p.conversation_id = c.id
+      AND p.user_id = sqlc.arg(user_a)
+)
+  AND EXISTS (
+    SELECT 1
+    FROM conversation_participants p
+    WHERE p.conversation_id = c.id
+      AND p.user_id = sqlc.arg(user_b)
+)
+  AND (
+    SELECT COUNT(*)
+    FROM conversation_participants p
+    WHERE p.conversation_id = c.id
+) = 2
+LIMIT 1;
+
+-- name: GetConversationsForUser :many
+SELECT
+    c.id,
+    c.created_at,
+    c.updated_at,
+    (
+        SELECT COUNT(*)
+        FROM messages m
+        WHERE m.conversation_id = c.id
+          AND m.sender_id <> p.user_id
+          AND (p.last_read_at IS NULL OR m.created_at > p.last_read_at)
+    ) AS unread_count
+FROM conversations c
+JOIN conversation_participants p ON p.conversation_id = c.id
+WHERE p.user_id = sqlc.arg(user_id)
+  AND (c.updated_at, c.id) < (sqlc.arg(before)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY c.updated_at DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetConversationParticipants :many
+SELECT *
+FROM conversation_participants
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY joined_at ASC;
+
+-- name: CanMessageUser :one
+SELECT EXISTS (
+    SELECT 1
+    FROM users u
+    WHERE u.id = sqlc.arg(recipient_id)
+      AND u.deletion_requested_at IS NULL
+      AND NOT EXISTS (
+        SELECT 1
+        FROM blocks b
+        WHERE (b.blocker_id = sqlc.arg(sender_id) AND b.blocked_id = u.id)
+           OR (b.blocker_id = u.id AND b.blocked_id = sqlc.arg(sender_id))
+    )
+      AND (
+        NOT u.is_protected
+        OR EXISTS (
+            SELECT 1
+            FROM follows f
+            WHERE f.follower_id = sqlc.arg(sender_id)
+              AND f.followee_id = u.id
+              AND f.status = 'accepted'
+        )
+    )
+);
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    sqlc.arg(conversation_id),
+    sqlc.arg(sender_id),
+    sqlc.arg(body)
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: GetMessages :many
+SELECT *
+FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND (created_at, id) < (sqlc.arg(before)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+SET last_read_at = NOW()
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND user_id = sqlc.arg(user_id);
@@ -0,0 +1,48 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.arg(body)
+)
+RETURNING *;
+
+-- name: GetDrafts :many
+SELECT *
+FROM drafts
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY updated_at DESC;
+
+-- name: GetDraft :one
+SELECT *
+FROM drafts
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = sqlc.arg(body),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: PublishDraft :one
+WITH published AS (
+    DELETE FROM drafts
+    WHERE id = sqlc.arg(id)
+      AND user_id = sqlc.arg(user_id)
+      AND updated_at = sqlc.arg(updated_at)
+    RETURNING user_id
+)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, flagged_at)
+SELECT gen_random_uuid(), NOW(), NOW(), sqlc.arg(body), user_id, CASE WHEN sqlc.arg(flagged)::bool THEN NOW() END
+FROM published
+RETURNING *;
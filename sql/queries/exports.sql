@@ -0,0 +1,45 @@
+-- name: CreateExport :one
+INSERT INTO exports (id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    'pending'
+)
+RETURNING *;
+
+-- name: GetExport :one
+SELECT *
+FROM exports
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: MarkExportReady :execrows
+UPDATE exports
+SET status = 'ready',
+    file_path = $2,
+    completed_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkExportFailed :exec
+UPDATE exports
+SET status = 'failed',
+    error = $2,
+    completed_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteExpiredExports :many
+DELETE FROM exports
+WHERE created_at <= sqlc.arg(cutoff)::timestamp
+RETURNING file_path;
+
+-- name: DeleteExportsOfPurgedUsers :many
+DELETE FROM exports e
+USING users u
+WHERE u.id = e.user_id
+  AND u.deletion_requested_at IS NOT NULL
+  AND u.deletion_requested_at <= sqlc.arg(cutoff)::timestamp
+RETURNING e.file_path;
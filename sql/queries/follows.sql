@@ -0,0 +1,55 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followee_id, created_at, status)
+SELECT
+    sqlc.arg(follower_id),
+    u.id,
+    NOW(),
+    CASE WHEN u.is_protected THEN 'pending' ELSE 'accepted' END
+FROM users u
+WHERE u.id = sqlc.arg(followee_id)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = sqlc.arg(follower_id) AND b.blocked_id = sqlc.arg(followee_id))
+       OR (b.blocker_id = sqlc.arg(followee_id) AND b.blocked_id = sqlc.arg(follower_id))
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: DeleteFollow :exec
+DELETE FROM follows
+WHERE follower_id = sqlc.arg(follower_id)
+  AND followee_id = sqlc.arg(followee_id);
+
+-- name: GetFollow :one
+SELECT *
+FROM follows
+WHERE follower_id = sqlc.arg(follower_id)
+  AND followee_id = sqlc.arg(followee_id);
+
+-- name: GetFollowRequests :many
+SELECT f.follower_id, f.created_at, u.display_name, u.avatar_key
+FROM follows f
+JOIN users u ON u.id = f.follower_id
+WHERE f.followee_id = sqlc.arg(followee_id)
+  AND f.status = 'pending'
+ORDER BY f.created_at ASC;
+
+-- name: ApproveFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted'
+WHERE follower_id = sqlc.arg(follower_id)
+  AND followee_id = sqlc.arg(followee_id)
+  AND status = 'pending';
+
+-- name: RejectFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = sqlc.arg(follower_id)
+  AND followee_id = sqlc.arg(followee_id)
+  AND status = 'pending';
+
+-- name: AcceptAllFollowRequests :many
+UPDATE follows
+SET status = 'accepted'
+WHERE followee_id = sqlc.arg(followee_id)
+  AND status = 'pending'
+RETURNING follower_id;
@@ -0,0 +1,82 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, unique_key, max_attempts, run_at)
+VALUES (
+    sqlc.arg(kind),
+    sqlc.arg(payload),
+    sqlc.narg(unique_key),
+    sqlc.arg(max_attempts),
+    sqlc.arg(run_at)
+)
+ON CONFLICT (unique_key) WHERE status IN ('queued', 'running') DO NOTHING
+RETURNING id;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id
+    FROM jobs
+    WHERE kind = ANY(sqlc.arg(kinds)::text[])
+      AND (
+        (status = 'queued' AND run_at <= NOW())
+        OR (status = 'running' AND locked_until < NOW())
+    )
+    ORDER BY run_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :execrows
+UPDATE jobs
+SET status = 'succeeded',
+    locked_until = NULL,
+    finished_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND status = 'running'
+  AND attempts = sqlc.arg(attempts);
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'queued',
+    locked_until = NULL,
+    run_at = sqlc.arg(run_at),
+    last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id)
+  AND status = 'running'
+  AND attempts = sqlc.arg(attempts);
+
+-- name: KillJob :execrows
+UPDATE jobs
+SET status = 'dead',
+    locked_until = NULL,
+    last_error = sqlc.arg(last_error),
+    finished_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND status = 'running'
+  AND attempts = sqlc.arg(attempts);
+
+-- name: GetJobs :many
+SELECT *
+FROM jobs
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (created_at, id) < (sqlc.arg(before)::timestamp, sqlc.arg(before_id)::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: RequeueDeadJob :execrows
+UPDATE jobs
+SET status = 'queued',
+    attempts = 0,
+    run_at = NOW(),
+    last_error = '',
+    finished_at = NULL
+WHERE id = sqlc.arg(id)
+  AND status = 'dead';
+
+-- name: PurgeFinishedJobs :execrows
+DELETE FROM jobs
+WHERE (status = 'succeeded' AND finished_at < sqlc.arg(succeeded_before))
+   OR (status = 'dead' AND finished_at < sqlc.arg(dead_before));
@@ -0,0 +1,48 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, content_type, width, height, size_bytes, blob_key, thumbnail_key)
+VALUES (
+    sqlc.arg(id),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.arg(content_type),
+    sqlc.arg(width),
+    sqlc.arg(height),
+    sqlc.arg(size_bytes),
+    sqlc.arg(blob_key),
+    sqlc.arg(thumbnail_key)
+)
+RETURNING *;
+
+-- name: GetMedia :one
+SELECT *
+FROM media
+WHERE id = sqlc.arg(id);
+
+-- name: AttachMedia :execrows
+UPDATE media
+SET chirp_id = sqlc.arg(chirp_id),
+    position = sqlc.arg(position),
+    alt_text = sqlc.arg(alt_text)
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+  AND chirp_id IS NULL;
+
+-- name: GetMediaForChirps :many
+SELECT *
+FROM media
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position ASC;
+
+-- name: DeleteOrphanedMedia :many
+DELETE FROM media
+WHERE chirp_id IS NULL
+  AND created_at < sqlc.arg(cutoff)
+RETURNING blob_key, thumbnail_key;
+
+-- name: DeleteMediaOfPurgedUsers :many
+DELETE FROM media m
+USING users u
+WHERE u.id = m.user_id
+  AND u.deletion_requested_at IS NOT NULL
+  AND u.deletion_requested_at <= sqlc.arg(cutoff)::timestamp
+RETURNING m.blob_key, m.thumbnail_key;
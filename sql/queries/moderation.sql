@@ -0,0 +1,85 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, reporter_id, target_type, chirp_id, user_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    sqlc.arg(reporter_id),
+    sqlc.arg(target_type),
+    sqlc.arg(chirp_id),
+    sqlc.arg(user_id),
+    sqlc.arg(reason),
+    sqlc.arg(details)
+)
+RETURNING *;
+
+-- name: GetOpenReports :many
+SELECT *
+FROM reports
+WHERE status = 'open'
+  AND user_id IS NOT NULL
+  AND (target_type = 'user' OR chirp_id IS NOT NULL)
+ORDER BY created_at ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetFlaggedChirps :many
+SELECT *
+FROM chirps
+WHERE flagged_at IS NOT NULL
+  AND hidden_at IS NULL
+  AND deleted_at IS NULL
+ORDER BY flagged_at ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ResolveChirpReports :execrows
+UPDATE reports
+SET status = sqlc.arg(status),
+    resolved_at = NOW(),
+    resolved_by = sqlc.arg(moderator_id)
+WHERE chirp_id = sqlc.arg(chirp_id)
+  AND status = 'open';
+
+-- name: ResolveUserReports :execrows
+UPDATE reports
+SET status = sqlc.arg(status),
+    resolved_at = NOW(),
+    resolved_by = sqlc.arg(moderator_id)
+WHERE user_id = sqlc.arg(user_id)
+  AND target_type = 'user'
+  AND status = 'open';
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(),
+    hidden_by = sqlc.arg(hidden_by),
+    flagged_at = NULL
+WHERE id = sqlc.arg(id);
+
+-- name: ClearChirpFlag :exec
+UPDATE chirps
+SET flagged_at = NULL
+WHERE id = sqlc.arg(id);
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, action, chirp_id, user_id, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    sqlc.arg(moderator_id),
+    sqlc.arg(action),
+    sqlc.arg(chirp_id),
+    sqlc.arg(user_id),
+    sqlc.arg(reason)
+)
+RETURNING *;
+
+-- name: GetChirpModerationActions :many
+SELECT *
+FROM moderation_actions
+WHERE chirp_id = sqlc.arg(chirp_id)
+ORDER BY created_at ASC;
+
+-- name: GetUserModerationActions :many
+SELECT *
+FROM moderation_actions
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY created_at ASC;
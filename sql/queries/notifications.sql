@@ -0,0 +1,203 @@
+-- name: UpsertNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, kind, group_key, chirp_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.arg(kind),
+    sqlc.arg(group_key),
+    sqlc.narg(chirp_id)
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW()
+RETURNING id;
+
+-- name: AddNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES (
+    sqlc.arg(notification_id),
+    sqlc.arg(actor_id),
+    NOW()
+)
+ON CONFLICT (notification_id, actor_id) DO NOTHING;
+
+-- name: GetNotifications :many
+SELECT
+    sqlc.embed(n),
+    (
+        SELECT COUNT(*)
+        FROM notification_actors a
+        WHERE a.notification_id = n.id
+          AND NOT EXISTS (
+            SELECT 1
+            FROM blocks b
+            WHERE (b.blocker_id = sqlc.arg(user_id) AND b.blocked_id = a.actor_id)
+               OR (b.blocker_id = a.actor_id AND b.blocked_id = sqlc.arg(user_id))
+        )
+          AND NOT EXISTS (
+            SELECT 1
+            FROM mutes m
+            WHERE m.muter_id = sqlc.arg(user_id)
+              AND m.muted_id = a.actor_id
+        )
+    ) AS actor_count
+FROM notifications n
+WHERE n.user_id = sqlc.arg(user_id)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM chirps c
+    WHERE c.id = n.chirp_id
+      AND c.deleted_at IS NOT NULL
+)
+  AND (
+    NOT EXISTS (
+        SELECT 1
+        FROM notification_actors a
+        WHERE a.notification_id = n.id
+    )
+    OR EXISTS (
+        SELECT 1
+        FROM notification_actors a
+        WHERE a.notification_id = n.id
+          AND NOT EXISTS (
+            SELECT 1
+            FROM blocks b
+            WHERE (b.blocker_id = sqlc.arg(user_id) AND b.blocked_id = a.actor_id)
+               OR (b.blocker_id = a.actor_id AND b.blocked_id = sqlc.arg(user_id))
+        )
+          AND NOT EXISTS (
+            SELECT 1
+            FROM mutes m
+            WHERE m.muter_id = sqlc.arg(user_id)
+              AND m.muted_id = a.actor_id
+        )
+    )
+)
+  AND (NOT sqlc.arg(unread_only)::bool OR n.read_at IS NULL)
+  AND (n.updated_at, n.id) < (sqlc.arg(before)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY n.updated_at DESC, n.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetNotificationActors :many
+SELECT notification_id, actor_id, created_at, username, display_name
+FROM (
+    SELECT
+        a.notification_id,
+        a.actor_id,
+        a.created_at,
+        u.username,
+        u.display_name,
+        ROW_NUMBER() OVER (PARTITION BY a.notification_id ORDER BY a.created_at DESC) AS actor_rank
+    FROM notification_actors a
+    JOIN users u ON u.id = a.actor_id
+    WHERE a.notification_id = ANY(sqlc.arg(notification_ids)::uuid[])
+      AND NOT EXISTS (
+        SELECT 1
+        FROM blocks b
+        WHERE (b.blocker_id = sqlc.arg(user_id) AND b.blocked_id = a.actor_id)
+           OR (b.blocker_id = a.actor_id AND b.blocked_id = sqlc.arg(user_id))
+    )
+      AND NOT EXISTS (
+        SELECT 1
+        FROM mutes m
+        WHERE m.muter_id = sqlc.arg(user_id)
+          AND m.muted_id = a.actor_id
+    )
+) ranked
+WHERE actor_rank <= sqlc.arg(per_notification)
+ORDER BY notification_id, created_at DESC;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications n
+WHERE n.user_id = sqlc.arg(user_id)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM chirps c
+    WHERE c.id = n.chirp_id
+      AND c.deleted_at IS NOT NULL
+)
+  AND (
+    NOT EXISTS (
+        SELECT 1
+        FROM notification_actors a
+        WHERE a.notification_id = n.id
+    )
+    OR EXISTS (
+        SELECT 1
+        FROM notification_actors a
+        WHERE a.notification_id = n.id
+          AND NOT EXISTS (
+            SELECT 1
+            FROM blocks b
+            WHERE (b.blocker_id = sqlc.arg(user_id) AND b.blocked_id = a.actor_id)
+               OR (b.blocker_id = a.actor_id AND b.blocked_id = sqlc.arg(user_id))
+        )
+          AND NOT EXISTS (
+            SELECT 1
+            FROM mutes m
+            WHERE m.muter_id = sqlc.arg(user_id)
+              AND m.muted_id = a.actor_id
+        )
+    )
+)
+  AND n.read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: MarkNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+  AND id = ANY(sqlc.arg(ids)::uuid[])
+  AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+  AND read_at IS NULL;
+
+-- name: GetMentionRecipients :many
+SELECT r.id
+FROM users r
+JOIN chirps c ON c.id = sqlc.arg(chirp_id)
+JOIN users u ON u.id = c.user_id
+WHERE LOWER(r.username) = ANY(sqlc.arg(usernames)::text[])
+  AND r.id <> c.user_id
+  AND r.deletion_requested_at IS NULL
+  AND c.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocks b
+    WHERE (b.blocker_id = r.id AND b.blocked_id = c.user_id)
+       OR (b.blocker_id = c.user_id AND b.blocked_id = r.id)
+)
+  AND (
+    NOT u.is_protected
+    OR c.user_id = r.id
+    OR EXISTS (
+        SELECT 1
+        FROM follows f
+        WHERE f.follower_id = r.id
+          AND f.followee_id = c.user_id
+          AND f.status = 'accepted'
+    )
+)
+  AND (c.hidden_at IS NULL OR c.user_id = r.id)
+  AND (
+    c.user_id = r.id
+    OR (
+        u.shadow_banned_at IS NULL
+        AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+    )
+);
+
+-- name: PurgeReadNotifications :execrows
+DELETE FROM notifications
+WHERE read_at < sqlc.arg(read_before);
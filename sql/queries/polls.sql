@@ -0,0 +1,60 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, chirp_id, closes_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    sqlc.arg(chirp_id),
+    sqlc.arg(closes_at)
+)
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, poll_id, position, text)
+VALUES (
+    gen_random_uuid(),
+    sqlc.arg(poll_id),
+    sqlc.arg(position),
+    sqlc.arg(text)
+)
+RETURNING *;
+
+-- name: GetPollByChirp :one
+SELECT *
+FROM polls
+WHERE chirp_id = sqlc.arg(chirp_id);
+
+-- name: GetPollsForChirps :many
+SELECT *
+FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollOptionTallies :many
+SELECT
+    o.id,
+    o.poll_id,
+    o.position,
+    o.text,
+    COUNT(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.option_id = o.id
+WHERE o.poll_id = ANY(sqlc.arg(poll_ids)::uuid[])
+GROUP BY o.id
+ORDER BY o.poll_id, o.position ASC;
+
+-- name: GetPollVotesByUser :many
+SELECT poll_id, option_id
+FROM poll_votes
+WHERE user_id = sqlc.arg(user_id)
+  AND poll_id = ANY(sqlc.arg(poll_ids)::uuid[]);
+
+-- name: CastPollVote :execrows
+INSERT INTO poll_votes (poll_id, user_id, option_id, created_at, updated_at)
+SELECT p.id, sqlc.arg(user_id), o.id, NOW(), NOW()
+FROM polls p
+JOIN poll_options o ON o.poll_id = p.id
+WHERE p.id = sqlc.arg(poll_id)
+  AND o.id = sqlc.arg(option_id)
+  AND p.closes_at > NOW()
+ON CONFLICT (poll_id, user_id) DO UPDATE
+SET option_id = EXCLUDED.option_id,
+    updated_at = NOW();
@@ -0,0 +1,52 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, publish_at, flagged)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.arg(body),
+    sqlc.arg(publish_at),
+    sqlc.arg(flagged)
+)
+RETURNING *;
+
+-- name: GetScheduledChirps :many
+SELECT *
+FROM scheduled_chirps
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY publish_at ASC;
+
+-- name: RescheduleChirp :one
+UPDATE scheduled_chirps
+SET publish_at = sqlc.arg(publish_at),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: CancelScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: PublishDueChirps :many
+WITH due AS (
+    DELETE FROM scheduled_chirps
+    WHERE id IN (
+        SELECT s.id
+        FROM scheduled_chirps s
+        JOIN users u ON u.id = s.user_id
+        WHERE s.publish_at <= NOW()
+          AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+        ORDER BY s.publish_at ASC
+        LIMIT sqlc.arg(batch_size)
+        FOR UPDATE OF s SKIP LOCKED
+    )
+    RETURNING id, user_id, body, flagged
+)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, flagged_at)
+SELECT id, NOW(), NOW(), body, user_id, CASE WHEN flagged THEN NOW() END
+FROM due
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
@@ -0,0 +1,14 @@
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (id, created_at, user_id, event)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+);
+
+-- name: GetUserSubscriptionEvents :many
+SELECT *
+FROM subscription_events
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -16,16 +16,122 @@ WHERE email = $1;
 
 -- name: UpdateUserEmail :exec
 UPDATE users
-SET email = $2
+SET email = $2,
+    updated_at = NOW()
 WHERE id = $1;
 
 -- name: UpdateUserPassword :exec
 UPDATE users
-SET hashed_password = $2
+SET hashed_password = $2,
+    updated_at = NOW()
 WHERE id = $1;
 
 
 -- name: UpdateUserChirpyRed :exec
 UPDATE users
 SET is_chirpy_red = TRUE
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: GetUserByIDForUpdate :one
+SELECT *
+FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: RequestUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deletion_requested_at IS NOT NULL
+  AND deletion_requested_at <= sqlc.arg(cutoff)::timestamp
+RETURNING avatar_key;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET display_name = $2,
+    bio = $3,
+    location = $4,
+    website = $5,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserAvatar :exec
+UPDATE users
+SET avatar_key = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetPublicProfile :one
+SELECT
+    u.id,
+    u.created_at,
+    u.username,
+    u.display_name,
+    u.bio,
+    u.location,
+    u.website,
+    u.avatar_key,
+    u.is_chirpy_red,
+    u.is_protected,
+    (SELECT COUNT(*) FROM follows f WHERE f.followee_id = u.id AND f.status = 'accepted') AS follower_count,
+    (SELECT COUNT(*) FROM follows f WHERE f.follower_id = u.id AND f.status = 'accepted') AS following_count,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id AND c.deleted_at IS NULL) AS chirp_count
+FROM users u
+WHERE u.id = $1
+  AND u.deletion_requested_at IS NULL;
+
+-- name: UpdateUserProtected :exec
+UPDATE users
+SET is_protected = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateUserSensitiveMedia :exec
+UPDATE users
+SET sensitive_media = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET suspended_at = NOW(),
+    suspended_until = $2,
+    suspension_reason = $3,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UnsuspendUser :exec
+UPDATE users
+SET suspended_at = NULL,
+    suspended_until = NULL,
+    suspension_reason = '',
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserShadowBanned :exec
+UPDATE users
+SET shadow_banned_at = CASE WHEN sqlc.arg(shadow_banned)::bool THEN COALESCE(shadow_banned_at, NOW()) END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: UpdateUsername :exec
+UPDATE users
+SET username = $2,
+    updated_at = NOW()
+WHERE id = $1;
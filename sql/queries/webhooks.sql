@@ -0,0 +1,155 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, event_types, global)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.arg(url),
+    sqlc.arg(secret),
+    sqlc.arg(event_types),
+    sqlc.arg(global)
+)
+RETURNING *;
+
+-- name: CountWebhookEndpoints :one
+SELECT COUNT(*)
+FROM webhook_endpoints
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: GetWebhookEndpoint :one
+SELECT *
+FROM webhook_endpoints
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: GetWebhookEndpoints :many
+SELECT *
+FROM webhook_endpoints
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY created_at ASC;
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET url = sqlc.arg(url),
+    event_types = sqlc.arg(event_types),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: EnableWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET disabled_at = NULL,
+    disabled_reason = '',
+    consecutive_failures = 0,
+    failing_since = NULL,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DisableWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET disabled_at = COALESCE(disabled_at, NOW()),
+    disabled_reason = sqlc.arg(reason),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: EnqueueWebhookEvent :exec
+SELECT enqueue_webhook_event(sqlc.arg(user_id), sqlc.arg(event_type), sqlc.arg(data)::json);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1,
+    last_attempt_at = NOW(),
+    next_attempt_at = sqlc.arg(lease_until)
+FROM webhook_endpoints w
+WHERE w.id = d.endpoint_id
+  AND d.id IN (
+    SELECT pd.id
+    FROM webhook_deliveries pd
+    JOIN webhook_endpoints pw ON pw.id = pd.endpoint_id
+    WHERE pd.status = 'pending'
+      AND pd.next_attempt_at <= NOW()
+      AND pw.disabled_at IS NULL
+    ORDER BY pd.next_attempt_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE OF pd SKIP LOCKED
+)
+RETURNING d.id, d.endpoint_id, d.event_type, d.payload, d.attempts, w.url, w.secret;
+
+-- name: RecordWebhookAttempt :exec
+INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, response_code, error, duration_ms)
+VALUES (
+    sqlc.arg(delivery_id),
+    sqlc.arg(attempted_at),
+    sqlc.narg(response_code),
+    sqlc.arg(error),
+    sqlc.arg(duration_ms)
+);
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded',
+    response_code = sqlc.arg(response_code),
+    delivered_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = CASE WHEN sqlc.narg(retry_at)::timestamp IS NULL THEN 'failed' ELSE 'pending' END,
+    next_attempt_at = COALESCE(sqlc.narg(retry_at), next_attempt_at),
+    response_code = sqlc.narg(response_code)
+WHERE id = sqlc.arg(id);
+
+-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0,
+    failing_since = NULL
+WHERE id = sqlc.arg(id);
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    failing_since = COALESCE(failing_since, NOW()),
+    disabled_at = CASE
+        WHEN disabled_at IS NULL
+            AND consecutive_failures + 1 >= sqlc.arg(disable_after_failures)
+            AND COALESCE(failing_since, NOW()) <= sqlc.arg(failing_before)
+        THEN NOW()
+        ELSE disabled_at
+    END,
+    disabled_reason = CASE
+        WHEN disabled_at IS NULL
+            AND consecutive_failures + 1 >= sqlc.arg(disable_after_failures)
+            AND COALESCE(failing_since, NOW()) <= sqlc.arg(failing_before)
+        THEN sqlc.arg(reason)
+        ELSE disabled_reason
+    END
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+  AND (created_at, id) < (sqlc.arg(before)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT *
+FROM webhook_delivery_attempts
+WHERE delivery_id = ANY(sqlc.arg(delivery_ids)::uuid[])
+ORDER BY id ASC;
+
+-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE created_at < sqlc.arg(created_before)
+  AND status <> 'pending';
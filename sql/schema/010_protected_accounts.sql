@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN is_protected BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE follows
+ADD COLUMN status TEXT NOT NULL DEFAULT 'accepted';
+
+-- +goose Down
+ALTER TABLE follows DROP COLUMN status;
+ALTER TABLE users DROP COLUMN is_protected;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE media (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    chirp_id UUID,
+    position INTEGER,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    blob_key TEXT NOT NULL,
+    thumbnail_key TEXT,
+    alt_text TEXT NOT NULL DEFAULT '',
+
+    UNIQUE (chirp_id, position),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    -- Media of a deleted chirp becomes an orphan and is swept up by the
+    -- cleanup job along with uploads that were never attached.
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL
+);
+
+CREATE INDEX media_orphans_idx ON media (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE media;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN content_warning TEXT NOT NULL DEFAULT '',
+    ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN sensitive_forced_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE users
+    ADD COLUMN sensitive_media TEXT NOT NULL DEFAULT 'collapse'
+        CHECK (sensitive_media IN ('hide', 'collapse', 'show')),
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+        CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN role,
+    DROP COLUMN sensitive_media;
+
+ALTER TABLE chirps
+    DROP COLUMN sensitive_forced_by,
+    DROP COLUMN sensitive,
+    DROP COLUMN content_warning;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN flagged_at TIMESTAMP;
+ALTER TABLE scheduled_chirps ADD COLUMN flagged BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX chirps_flagged_at_idx ON chirps (flagged_at) WHERE flagged_at IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE scheduled_chirps DROP COLUMN flagged;
+ALTER TABLE chirps DROP COLUMN flagged_at;
@@ -0,0 +1,70 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN hidden_at TIMESTAMP,
+    ADD COLUMN hidden_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE users
+    ADD COLUMN suspended_at TIMESTAMP,
+    ADD COLUMN suspended_until TIMESTAMP,
+    ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    reporter_id UUID NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('chirp', 'user')),
+    -- For chirp reports user_id is the chirp's author, so reports can be
+    -- looked up by user either way.
+    chirp_id UUID,
+    user_id UUID NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm', 'misinformation', 'impersonation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'dismissed', 'actioned')),
+    resolved_at TIMESTAMP,
+    resolved_by UUID,
+
+    CHECK ((target_type = 'chirp') = (chirp_id IS NOT NULL)),
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- A user can only have one open report against each target.
+CREATE UNIQUE INDEX reports_open_chirp_idx ON reports (reporter_id, chirp_id)
+    WHERE status = 'open' AND chirp_id IS NOT NULL;
+CREATE UNIQUE INDEX reports_open_user_idx ON reports (reporter_id, user_id)
+    WHERE status = 'open' AND chirp_id IS NULL;
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+-- moderation_actions is the permanent record of what moderators did. The
+-- chirp_id isn't a foreign key so that removals stay on record after the
+-- chirp is gone.
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID,
+    action TEXT NOT NULL CHECK (action IN ('dismiss', 'hide_chirp', 'remove_chirp', 'suspend_user')),
+    chirp_id UUID,
+    user_id UUID,
+    reason TEXT NOT NULL DEFAULT '',
+
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX moderation_actions_chirp_id_idx ON moderation_actions (chirp_id);
+CREATE INDEX moderation_actions_user_id_idx ON moderation_actions (user_id);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+
+ALTER TABLE users
+    DROP COLUMN suspension_reason,
+    DROP COLUMN suspended_until,
+    DROP COLUMN suspended_at;
+
+ALTER TABLE chirps
+    DROP COLUMN hidden_by,
+    DROP COLUMN hidden_at;
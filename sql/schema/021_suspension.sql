@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN shadow_banned_at TIMESTAMP;
+
+ALTER TABLE moderation_actions DROP CONSTRAINT moderation_actions_action_check;
+ALTER TABLE moderation_actions ADD CONSTRAINT moderation_actions_action_check
+    CHECK (action IN ('dismiss', 'hide_chirp', 'remove_chirp', 'suspend_user', 'unsuspend_user', 'shadow_ban_user', 'lift_shadow_ban'));
+
+-- +goose Down
+DELETE FROM moderation_actions WHERE action IN ('unsuspend_user', 'shadow_ban_user', 'lift_shadow_ban');
+ALTER TABLE moderation_actions DROP CONSTRAINT moderation_actions_action_check;
+ALTER TABLE moderation_actions ADD CONSTRAINT moderation_actions_action_check
+    CHECK (action IN ('dismiss', 'hide_chirp', 'remove_chirp', 'suspend_user'));
+
+ALTER TABLE users DROP COLUMN shadow_banned_at;
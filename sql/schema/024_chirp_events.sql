@@ -0,0 +1,72 @@
+-- +goose Up
+-- chirp_events records every change to a chirp that live clients need to
+-- hear about, and announces it on the chirp_events notification channel so
+-- every server instance can push it to its own subscribers. Rows are kept
+-- for a while so clients can resume from the last event they saw.
+CREATE TABLE chirp_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    kind TEXT NOT NULL CHECK (kind IN ('created', 'updated', 'deleted')),
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL
+);
+
+CREATE INDEX chirp_events_user_id_idx ON chirp_events (user_id, id);
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    chirp chirps;
+    event chirp_events;
+    event_kind TEXT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        chirp := NEW;
+        event_kind := 'created';
+    ELSIF TG_OP = 'DELETE' THEN
+        -- Purging a tombstone isn't news; its deletion already went out.
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        chirp := OLD;
+        event_kind := 'deleted';
+    ELSE
+        chirp := NEW;
+        IF (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL)
+            OR (OLD.hidden_at IS NULL AND NEW.hidden_at IS NOT NULL) THEN
+            event_kind := 'deleted';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            event_kind := 'created';
+        ELSIF NEW.deleted_at IS NULL
+            AND (OLD.body, OLD.content_warning, OLD.sensitive)
+                IS DISTINCT FROM (NEW.body, NEW.content_warning, NEW.sensitive) THEN
+            event_kind := 'updated';
+        ELSE
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    INSERT INTO chirp_events (kind, chirp_id, user_id)
+    VALUES (event_kind, chirp.id, chirp.user_id)
+    RETURNING * INTO event;
+    PERFORM pg_notify('chirp_events', json_build_object(
+        'id', event.id,
+        'created_at', to_char(event.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+        'kind', event.kind,
+        'chirp_id', event.chirp_id,
+        'user_id', event.user_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_events
+AFTER INSERT OR UPDATE OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION record_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirp_events ON chirps;
+DROP FUNCTION record_chirp_event();
+DROP TABLE chirp_events;
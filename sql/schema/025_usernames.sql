@@ -0,0 +1,9 @@
+-- +goose Up
+-- Usernames are optional handles used for @mentions. They're unique
+-- regardless of case but displayed as the user typed them.
+ALTER TABLE users ADD COLUMN username TEXT;
+
+CREATE UNIQUE INDEX users_username_key ON users (LOWER(username));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN username;
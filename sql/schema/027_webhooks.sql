@@ -0,0 +1,106 @@
+-- +goose Up
+-- Webhook endpoints are HTTPS URLs users register to hear about events on
+-- their own account. Admins can register global endpoints that hear about
+-- every user's events. Endpoints that keep failing are disabled.
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    global BOOLEAN NOT NULL DEFAULT FALSE,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    failing_since TIMESTAMP,
+    disabled_at TIMESTAMP,
+    disabled_reason TEXT NOT NULL DEFAULT '',
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_endpoints_user_id_idx ON webhook_endpoints (user_id);
+
+-- Each event an endpoint subscribes to becomes a delivery, which is
+-- retried until it succeeds or runs out of attempts.
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    endpoint_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    response_code INTEGER,
+    delivered_at TIMESTAMP,
+
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at DESC);
+
+-- The delivery log: one row per attempt.
+CREATE TABLE webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id UUID NOT NULL,
+    attempted_at TIMESTAMP NOT NULL,
+    response_code INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL,
+
+    FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_delivery_attempts_delivery_id_idx ON webhook_delivery_attempts (delivery_id, id);
+
+-- enqueue_webhook_event creates a delivery of an event about a user for
+-- every enabled endpoint that wants it.
+-- +goose StatementBegin
+CREATE FUNCTION enqueue_webhook_event(event_user_id UUID, event_type TEXT, event_data JSON) RETURNS VOID AS $$
+    INSERT INTO webhook_deliveries (id, created_at, endpoint_id, event_type, payload, next_attempt_at)
+    SELECT
+        gen_random_uuid(),
+        NOW(),
+        w.id,
+        event_type,
+        json_build_object(
+            'type', event_type,
+            'created_at', to_char(NOW() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+            'data', event_data
+        )::text,
+        NOW()
+    FROM webhook_endpoints w
+    WHERE (w.user_id = event_user_id OR w.global)
+      AND w.disabled_at IS NULL
+      AND event_type = ANY(w.event_types);
+$$ LANGUAGE sql;
+-- +goose StatementEnd
+
+-- Chirp changes reach webhooks through chirp_events, so every way a chirp
+-- can be created, edited or deleted is covered in the same transaction.
+-- +goose StatementBegin
+CREATE FUNCTION enqueue_chirp_webhooks() RETURNS trigger AS $$
+BEGIN
+    PERFORM enqueue_webhook_event(NEW.user_id, 'chirp.' || NEW.kind, json_build_object(
+        'chirp_id', NEW.chirp_id,
+        'user_id', NEW.user_id
+    ));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_event_webhooks
+AFTER INSERT ON chirp_events
+FOR EACH ROW EXECUTE FUNCTION enqueue_chirp_webhooks();
+
+-- +goose Down
+DROP TRIGGER chirp_event_webhooks ON chirp_events;
+DROP FUNCTION enqueue_chirp_webhooks();
+DROP FUNCTION enqueue_webhook_event(UUID, TEXT, JSON);
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- jobs is a queue of background work. Workers claim due jobs with
+-- FOR UPDATE SKIP LOCKED and hold them until locked_until; a job whose
+-- worker died is claimed again once that passes. Failed jobs are retried
+-- with backoff until max_attempts, then left as 'dead' for an admin to
+-- look at. While a job with a unique_key is queued or running, enqueuing
+-- another with the same key does nothing.
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL DEFAULT '{}',
+    unique_key TEXT,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    finished_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX jobs_unique_key ON jobs (unique_key) WHERE status IN ('queued', 'running');
+CREATE INDEX jobs_queued_idx ON jobs (run_at) WHERE status = 'queued';
+CREATE INDEX jobs_running_idx ON jobs (locked_until) WHERE status = 'running';
+CREATE INDEX jobs_finished_at_idx ON jobs (finished_at) WHERE finished_at IS NOT NULL;
+
+-- Exports used to be built by goroutines started at boot; hand the ones
+-- still pending to the queue.
+INSERT INTO jobs (kind, payload, unique_key, max_attempts, run_at)
+SELECT
+    'build_export',
+    json_build_object('export_id', id, 'user_id', user_id)::text,
+    'build_export:' || id,
+    5,
+    NOW()
+FROM exports
+WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE jobs;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- Reports and moderation actions are kept after the chirps and users they
+-- are about are deleted, with the reference cleared, so removing a chirp
+-- or purging an account doesn't erase its moderation history.
+ALTER TABLE reports
+    DROP CONSTRAINT reports_check,
+    DROP CONSTRAINT reports_chirp_id_fkey,
+    DROP CONSTRAINT reports_user_id_fkey,
+    ALTER COLUMN user_id DROP NOT NULL,
+    ADD CONSTRAINT reports_check CHECK (target_type = 'chirp' OR chirp_id IS NULL),
+    ADD CONSTRAINT reports_chirp_id_fkey FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL,
+    ADD CONSTRAINT reports_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- Chirp reports can now lose their chirp_id, so user reports are told
+-- apart by target_type instead.
+DROP INDEX reports_open_user_idx;
+CREATE UNIQUE INDEX reports_open_user_idx ON reports (reporter_id, user_id)
+    WHERE status = 'open' AND target_type = 'user';
+
+ALTER TABLE moderation_actions
+    DROP CONSTRAINT moderation_actions_user_id_fkey,
+    ADD CONSTRAINT moderation_actions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+DELETE FROM moderation_actions WHERE user_id IS NULL;
+DELETE FROM reports
+WHERE user_id IS NULL
+   OR (target_type = 'chirp' AND chirp_id IS NULL);
+
+ALTER TABLE moderation_actions
+    DROP CONSTRAINT moderation_actions_user_id_fkey,
+    ADD CONSTRAINT moderation_actions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+DROP INDEX reports_open_user_idx;
+CREATE UNIQUE INDEX reports_open_user_idx ON reports (reporter_id, user_id)
+    WHERE status = 'open' AND chirp_id IS NULL;
+
+ALTER TABLE reports
+    DROP CONSTRAINT reports_user_id_fkey,
+    DROP CONSTRAINT reports_chirp_id_fkey,
+    DROP CONSTRAINT reports_check,
+    ALTER COLUMN user_id SET NOT NULL,
+    ADD CONSTRAINT reports_check CHECK ((target_type = 'chirp') = (chirp_id IS NOT NULL)),
+    ADD CONSTRAINT reports_chirp_id_fkey FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+    ADD CONSTRAINT reports_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,125 @@
+-- +goose Up
+-- Chirp events record who could see the chirp when it changed, so a
+-- deletion can still be sent to the right viewers once the chirp row is
+-- gone, as it is after a moderator removes it.
+ALTER TABLE chirp_events
+    ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN author_protected BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN author_listed BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    chirp chirps;
+    event chirp_events;
+    event_kind TEXT;
+    author users;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        chirp := NEW;
+        event_kind := 'created';
+    ELSIF TG_OP = 'DELETE' THEN
+        -- Purging a tombstone isn't news; its deletion already went out.
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        chirp := OLD;
+        event_kind := 'deleted';
+    ELSE
+        chirp := NEW;
+        IF (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL)
+            OR (OLD.hidden_at IS NULL AND NEW.hidden_at IS NOT NULL) THEN
+            event_kind := 'deleted';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            event_kind := 'created';
+        ELSIF NEW.deleted_at IS NULL
+            AND (OLD.body, OLD.content_warning, OLD.sensitive)
+                IS DISTINCT FROM (NEW.body, NEW.content_warning, NEW.sensitive) THEN
+            event_kind := 'updated';
+        ELSE
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    -- A purge that cascades from users can't see the author any more; its
+    -- events are treated as protected and unlisted, so nobody is told.
+    SELECT * INTO author FROM users WHERE id = chirp.user_id;
+    INSERT INTO chirp_events (kind, chirp_id, user_id, sensitive, author_protected, author_listed)
+    VALUES (
+        event_kind,
+        chirp.id,
+        chirp.user_id,
+        chirp.sensitive,
+        COALESCE(author.is_protected, true),
+        COALESCE(
+            author.shadow_banned_at IS NULL
+                AND (author.suspended_at IS NULL OR author.suspended_until <= NOW()),
+            false
+        )
+    )
+    RETURNING * INTO event;
+    PERFORM pg_notify('chirp_events', json_build_object(
+        'id', event.id,
+        'created_at', to_char(event.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+        'kind', event.kind,
+        'chirp_id', event.chirp_id,
+        'user_id', event.user_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    chirp chirps;
+    event chirp_events;
+    event_kind TEXT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        chirp := NEW;
+        event_kind := 'created';
+    ELSIF TG_OP = 'DELETE' THEN
+        -- Purging a tombstone isn't news; its deletion already went out.
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        chirp := OLD;
+        event_kind := 'deleted';
+    ELSE
+        chirp := NEW;
+        IF (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL)
+            OR (OLD.hidden_at IS NULL AND NEW.hidden_at IS NOT NULL) THEN
+            event_kind := 'deleted';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            event_kind := 'created';
+        ELSIF NEW.deleted_at IS NULL
+            AND (OLD.body, OLD.content_warning, OLD.sensitive)
+                IS DISTINCT FROM (NEW.body, NEW.content_warning, NEW.sensitive) THEN
+            event_kind := 'updated';
+        ELSE
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    INSERT INTO chirp_events (kind, chirp_id, user_id)
+    VALUES (event_kind, chirp.id, chirp.user_id)
+    RETURNING * INTO event;
+    PERFORM pg_notify('chirp_events', json_build_object(
+        'id', event.id,
+        'created_at', to_char(event.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+        'kind', event.kind,
+        'chirp_id', event.chirp_id,
+        'user_id', event.user_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+ALTER TABLE chirp_events
+    DROP COLUMN sensitive,
+    DROP COLUMN author_protected,
+    DROP COLUMN author_listed;
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// suspended reports whether a suspension recorded in a user's
+// suspended_at and suspended_until columns is currently in effect.
+// Suspensions without an end date last until they're lifted.
+func suspended(suspendedAt, suspendedUntil sql.NullTime) bool {
+	return suspendedAt.Valid && (!suspendedUntil.Valid || time.Now().Before(suspendedUntil.Time))
+}
+
+func respondSuspended(w http.ResponseWriter, reason string, until sql.NullTime) {
+	msg := "Account is suspended"
+	if until.Valid {
+		msg += " until " + until.Time.UTC().Format(time.RFC3339)
+	}
+	if reason != "" {
+		msg += fmt.Sprintf(": %s", reason)
+	}
+	respondWithError(w, http.StatusForbidden, msg)
+}
+
+// ensureCanPost responds with 403 and returns false if userID is
+// suspended. Access tokens outlive the refresh tokens revoked on
+// suspension, so anything that publishes content checks this itself.
+func (api *apiConfig) ensureCanPost(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user")
+		return false
+	}
+	if suspended(user.SuspendedAt, user.SuspendedUntil) {
+		respondSuspended(w, user.SuspensionReason, user.SuspendedUntil)
+		return false
+	}
+	return true
+}
+
+// isSuspended reports whether userID is currently suspended. Suspension
+// revokes refresh tokens but can't reach connections that are already
+// open, so live streams check this as they go.
+func (api *apiConfig) isSuspended(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := api.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return suspended(user.SuspendedAt, user.SuspendedUntil), nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Madlite/chirpy/internal/auth"
+	"github.com/Madlite/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var errEmailTaken = errors.New("email is already in use")
+
+// userUpdate is a partial update to a user. Nil fields are left as they
+// are.
+type userUpdate struct {
+	Email    *string
+	Password *string
+}
+
+// changes returns the part of u that would change user: an email that is
+// already theirs, or the password they already have, is dropped, so it
+// isn't audited and, for the password, doesn't sign them out.
+func (u userUpdate) changes(user database.User) (userUpdate, error) {
+	if u.Email != nil && *u.Email == user.Email {
+		u.Email = nil
+	}
+	if u.Password != nil {
+		same, err := auth.CheckPasswordHash(*u.Password, user.HashedPassword)
+		if err != nil {
+			return userUpdate{}, err
+		}
+		if same {
+			u.Password = nil
+		}
+	}
+	return u, nil
+}
+
+// updateUser applies update in one transaction and returns the updated
+// user. A new password signs the user out everywhere else: all of their
+// refresh tokens are revoked, and the one returned, for the caller to
+// carry on with, is the only one left. Without a new password the
+// returned refresh token is empty.
+func (api *apiConfig) updateUser(ctx context.Context, userID uuid.UUID, update userUpdate) (database.User, string, error) {
+	var hashedPassword, refreshToken string
+	if update.Password != nil {
+		var err error
+		hashedPassword, err = auth.HashPassword(*update.Password)
+		if err != nil {
+			return database.User{}, "", err
+		}
+		refreshToken, err = auth.MakeRefreshToken()
+		if err != nil {
+			return database.User{}, "", err
+		}
+	}
+
+	var user database.User
+	err := api.withTx(ctx, func(q *database.Queries) error {
+		if update.Email != nil {
+			err := q.UpdateUserEmail(ctx, database.UpdateUserEmailParams{
+				ID:    userID,
+				Email: *update.Email,
+			})
+			if isUniqueViolation(err) {
+				return errEmailTaken
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if update.Password != nil {
+			err := q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+				ID:             userID,
+				HashedPassword: hashedPassword,
+			})
+			if err != nil {
+				return err
+			}
+			err = q.RevokeUserRefreshTokens(ctx, userID)
+			if err != nil {
+				return err
+			}
+			_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+				Token:  refreshToken,
+				UserID: userID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		user, err = q.GetUserByID(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return database.User{}, "", err
+	}
+	return user, refreshToken, nil
+}
+
+// startSession issues a refresh token for a user who has just signed in.
+// Signing in cancels a pending account deletion; both happen in one
+// transaction, so a user is never left with a session on an account that
+// is still due to be deleted.
+func (api *apiConfig) startSession(ctx context.Context, user database.User) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	err = api.withTx(ctx, func(q *database.Queries) error {
+		if user.DeletionRequestedAt.Valid {
+			err := q.CancelUserDeletion(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			Token:  refreshToken,
+			UserID: user.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}